@@ -5,6 +5,7 @@ import (
 	"image"
 	"image/color"
 	"math"
+	"reflect"
 	"sync"
 
 	"github.com/harbdog/raycaster-go/geom"
@@ -14,10 +15,35 @@ import (
 )
 
 const (
-	// maximum number of concurrent tasks for large task sets (e.g. floor and sprite casting)
-	maxConcurrent = 100
+	// default maximum number of concurrent tasks for large task sets (e.g. level and sprite casting)
+	defaultMaxConcurrent = 100
+
+	// default amount that walls along the X-axis side are darkened to differentiate corners
+	defaultSideShading = 12
+
+	// minimum distance used to project walls, avoids integer overflow of the line height for extremely close walls
+	minProjectionDist = 1e-4
+
+	// min/max FOV angle (degrees) to avoid degenerate camera plane vectors
+	minFovAngle = 1.0
+	maxFovAngle = 170.0
+
+	// default FOV angle (degrees) and depth
+	defaultFovAngle = 70.0
+	defaultFovDepth = 1.0
 )
 
+// cameraPose is a snapshot of the camera position and orientation, taken at the start of each raycast
+// so changes to the camera while casting cannot tear the rendered frame
+type cameraPose struct {
+	pos, dir, plane geom.Vector2
+
+	posZ, camZ float64
+
+	headingAngle, pitchAngle float64
+	pitch                    int
+}
+
 // Camera Class that represents a camera in terms of raycasting.
 // Contains methods to move the camera, and handles projection to,
 // set the rectangle slice position and height,
@@ -25,6 +51,16 @@ type Camera struct {
 	//--camera position, init to start position--//
 	pos *geom.Vector2
 
+	// start position restored on reset
+	startPos *geom.Vector2
+
+	// camera pose used while raycasting and drawing, guarded by poseMu when taken
+	pose   cameraPose
+	poseMu sync.Mutex
+
+	// called when the camera position moves into a different map cell
+	onEnterCell func(x, y int)
+
 	// vertical camera strafing up/down, for jumping/crouching
 	camZ float64
 	posZ float64
@@ -36,14 +72,29 @@ type Camera struct {
 	//--the 2d raycaster version of camera plane, adjust y component to change FOV (ratio between this and dir x resizes FOV)--//
 	plane *geom.Vector2
 
-	//--viewport width and height--//
+	//--raycasted view width and height--//
 	w int
 	h int
 
+	//--viewport width and height the raycasted view is drawn at--//
+	viewW int
+	viewH int
+
+	// ratio of the raycasted view size to the viewport size, and the image it is rendered to when not 1.0
+	renderScale float64
+	renderImage *ebiten.Image
+
+	// set once the camera images have been disposed, making further updates and draws no-ops
+	closed bool
+
 	// camera pitch
 	pitch      int
 	pitchAngle float64
 
+	// limits of the camera pitch angle (in radians), guarded by poseMu
+	minPitchAngle float64
+	maxPitchAngle float64
+
 	// camera fov angle and depth
 	fovAngle, fovDepth float64
 
@@ -52,10 +103,31 @@ type Camera struct {
 	mapWidth  int
 	mapHeight int
 
+	// cells of the first level reached by rays, flagged atomically since levels are cast concurrently
+	explored []uint32
+
 	//--floor box, sky box textures--//
 	floor *ebiten.Image
 	sky   *ebiten.Image
 
+	// how fast the sky box texture scrolls horizontally with the camera heading (0 for static sky)
+	skyScrollFactor float64
+
+	// color filling the view where no sky, floor, or wall is drawn
+	backgroundColor color.RGBA
+
+	// how much more of the skybox texture is revealed vertically when pitching up (0 to stretch it to fit)
+	skyTilt float64
+
+	// repeating ceiling texture (nil to show sky box)
+	ceiling *image.RGBA
+
+	// number of map cells the floor and ceiling textures span before repeating
+	floorTexScale float64
+
+	// filter used when drawing wall, floor, sky, and sprite textures
+	textureFilter ebiten.Filter
+
 	//--texture width--//
 	texSize int
 
@@ -64,22 +136,101 @@ type Camera struct {
 	floorLvl *horLevel
 	slices   []*image.Rectangle
 
+	// glass wall slices drawn over each level, by level number then glass layer from the nearest
+	glassLvls [][]*level
+	// perpendicular distance to the glass wall of each glass layer of the first level for each column (-1 if none)
+	glassDepth [][]float64
+
+	// wall slices of the short walls seen over in front of each level, by level number then short wall from the nearest
+	shortWallLvls [][]*level
+	// perpendicular distance (-1 if none) and top screen row of each short wall of the first level for each column,
+	// sprites behind them are clipped above their top
+	shortWallDepth [][]float64
+	shortWallTop   [][]int
+
+	// wall decal slices drawn over each level, by level number, then the wall behind the short walls followed by
+	// each short wall, then decal layer. The decals on each wall face are looked up by wallDecals.
+	decalLvls  [][][]*level
+	wallDecals map[wallDecalKey][]*WallDecal
+
 	// zbuffer for sprite casting
 	zBuffer []float64
+
+	// wall raycast results of the first level for each column
+	columns []ColumnInfo
 	// sprites
 	sprites    []Sprite
 	spriteLvls []*level
+	// sprites added to the camera to be cast by UpdateManaged
+	managedSprites []Sprite
+	// sprite levels allocated during previous raycasts, to be reused
+	spriteLvlCache []*level
 	//arrays used to sort the sprites
 	spriteOrder    []int
 	spriteDistance []float64
+	// perpendicular depth of each sorted sprite from the camera plane, set while casting
+	spriteDepth []float64
+	// render order of each sorted sprite, used to break ties in distance
+	spriteRenderOrder []int
+	// number of sprites culled before casting during the last update
+	spritesCulled int
+	// distance beyond which sprites are cast in wider stripes (-1 to disable)
+	spriteLOD float64
+
+	// distance subtracted from sprite depth when compared against wall depth
+	spriteDepthBias float64
+
+	// mapping of screen columns to ray directions
+	projection Projection
+
+	// amount of fisheye correction, 1 for projecting by perpendicular distance and 0 for the real distance
+	fisheyeCorrection float64
+	// sorted order index of each sprite from the last raycast by its index in the sprites, -1 if not cast
+	spriteOrdIndex []int
+	// index in the sprites of each sprite from the last raycast that is comparable, for sprite lookups
+	spriteIndex map[Sprite]int
 
 	tex TextureHandler
 
+	// maximum number of concurrent tasks for each level and for sprite casting
+	maxConcurrent int
+
+	// whether levels and sprites are cast concurrently, otherwise serially
+	concurrent bool
+
+	// whether casting of walls, floor, and ceiling is skipped when the pose has not changed since they were last cast,
+	// unless a recast is forced
+	skipStaticRecast bool
+	forceRecast      bool
+	// pose and light pulse the walls, floor, and ceiling were last cast with, and the convergence found
+	castPose                cameraPose
+	castPulseLight          float64
+	wallConvergenceDistance float64
+	wallConvergencePoint    *geom3d.Vector3
+
 	//--simulates torch light, as if player was carrying a radial light--//
 	lightFalloff float64
 
-	//--global illumination for whole level (sun brightness)--//
-	globalIllumination float64
+	// amount that walls along the X-axis side are darkened to differentiate corners
+	sideShading int
+
+	//--global illumination for whole level (sun brightness) for each color channel--//
+	globalIllumination lightRGB
+
+	// light falloff and illumination overrides for specific levels
+	levelLighting map[int]levelLighting
+
+	// point lights positioned on the map
+	lights []*Light
+
+	// transient light pulse added to all lighting, decaying over its remaining updates
+	pulseIntensity float64
+	pulseDuration  int
+	pulseRemaining int
+	pulseLight     float64
+
+	// screen overlays drawn on top of the raycasted view
+	overlays []*Overlay
 
 	// controls the min/max color tinting of the textures when fully shadowed (min) or lighted (max)
 	minLightRGB color.NRGBA
@@ -88,16 +239,54 @@ type Camera struct {
 	// maximum distance to render raycasted objects
 	renderDistance float64
 
-	// point at which the center of the screen converges (for reticle use)
+	// distance fog color and the distances where it starts and fully obscures raycasted objects
+	fogRGBA          color.RGBA
+	fogStart, fogEnd float64
+
+	// point at which the center of the screen converges (for reticle use),
+	// guarded by convergenceMu since levels and sprites are cast concurrently
+	convergenceMu       sync.Mutex
 	convergenceDistance float64
 	convergencePoint    *geom3d.Vector3
 }
 
-// NewCamera initalizes a Camera object
+// NewCamera initalizes a Camera object, starting at the spawn pose of the map when it implements SpawnMap
+// and the spawn position is valid, otherwise at the default start position
 func NewCamera(width int, height int, texSize int, mapObj Map, tex TextureHandler) *Camera {
+	if spawnMap, ok := mapObj.(SpawnMap); ok {
+		pos, headingAngle := spawnMap.SpawnPose()
+		if pos != nil && validateStartPosition(mapObj, pos) == nil {
+			return newCamera(width, height, texSize, mapObj, tex, pos, headingAngle)
+		}
+		// a spawn position outside of the map or inside a wall falls back to the default start position
+	}
+	return newCamera(width, height, texSize, mapObj, tex, &geom.Vector2{X: 1.0, Y: 1.0}, 0)
+}
+
+// NewCameraAt initalizes a Camera object at the given start position and heading angle,
+// so the initial raycast already reflects them.
+// Returns an error if the start position is outside of the map or inside a wall on the first level.
+func NewCameraAt(width int, height int, texSize int, mapObj Map, tex TextureHandler, pos *geom.Vector2, headingAngle float64) (*Camera, error) {
+	if err := validateStartPosition(mapObj, pos); err != nil {
+		return nil, err
+	}
+	return newCamera(width, height, texSize, mapObj, tex, pos, headingAngle), nil
+}
 
-	fmt.Printf("Initializing Camera\n")
+// validateStartPosition returns an error if the position is outside of the map or inside a wall on the first level
+func validateStartPosition(mapObj Map, pos *geom.Vector2) error {
+	firstLevel := mapObj.Level(0)
+	mapX, mapY := int(math.Floor(pos.X)), int(math.Floor(pos.Y))
+	if mapX < 0 || mapY < 0 || mapX >= len(firstLevel) || mapY >= len(firstLevel[mapX]) {
+		return fmt.Errorf("start position (%v, %v) is outside of the map", pos.X, pos.Y)
+	}
+	if firstLevel[mapX][mapY] > 0 {
+		return fmt.Errorf("start position (%v, %v) is inside a wall", pos.X, pos.Y)
+	}
+	return nil
+}
 
+func newCamera(width int, height int, texSize int, mapObj Map, tex TextureHandler, pos *geom.Vector2, headingAngle float64) *Camera {
 	c := &Camera{}
 
 	//--map setup
@@ -105,25 +294,34 @@ func NewCamera(width int, height int, texSize int, mapObj Map, tex TextureHandle
 	firstLevel := mapObj.Level(0)
 	c.mapWidth = len(firstLevel)
 	c.mapHeight = len(firstLevel[0])
+	c.explored = make([]uint32, c.mapWidth*c.mapHeight)
 
 	//--camera position, init to some start position--//
-	c.pos = &geom.Vector2{X: 1.0, Y: 1.0}
+	c.SetStartPosition(pos)
+	c.pos = c.startPos.Copy()
 	c.camZ = 0.0
-	c.SetHeadingAngle(0)
-	c.SetPitchAngle(0)
+	c.SetHeadingAngle(headingAngle)
+	c.SetPitchLimits(-geom.HalfPi, geom.HalfPi)
 
-	fovDegrees := 70.0
-	fovDepth := 1.0
-	c.SetFovAngle(fovDegrees, fovDepth)
+	c.SetFovAngle(defaultFovAngle, defaultFovDepth)
 
 	// defaults for lighting and distant shadow
 	c.SetRenderDistance(-1)
 	c.SetLightFalloff(-100)
+	c.SetSideShading(defaultSideShading)
 	c.SetGlobalIllumination(300)
 	c.SetLightRGB(color.NRGBA{R: 0, G: 0, B: 0}, color.NRGBA{R: 255, G: 255, B: 255})
+	c.SetFog(color.RGBA{}, 0, -1)
 
 	c.texSize = texSize
 	c.tex = tex
+	c.SetTextureFilter(ebiten.FilterNearest)
+	c.SetFloorTextureScale(1)
+	c.SetMaxConcurrent(defaultMaxConcurrent)
+	c.SetSpriteLOD(-1)
+	c.SetFisheyeCorrection(1)
+	c.SetConcurrency(true)
+	c.renderScale = 1.0
 	c.SetViewSize(width, height)
 
 	c.sprites = []Sprite{}
@@ -138,35 +336,149 @@ func NewCamera(width int, height int, texSize int, mapObj Map, tex TextureHandle
 	return c
 }
 
-// SetViewSize sets the camera resolution
+// SetViewSize sets the camera resolution, can be called between updates to resize the view
+// while preserving the camera position, direction, and FOV
 func (c *Camera) SetViewSize(width, height int) {
+	if c.closed {
+		return
+	}
+
+	c.viewW = width
+	c.viewH = height
+
+	// raycasted view size based on render scale
+	width = geom.MaxInt(int(float64(width)*c.renderScale), 1)
+	height = geom.MaxInt(int(float64(height)*c.renderScale), 1)
+
+	if c.h > 0 {
+		// vertical camera position is relative to the view height
+		c.poseMu.Lock()
+		c.camZ = c.camZ * float64(height) / float64(c.h)
+		c.poseMu.Unlock()
+	}
+
 	c.w = width
 	c.h = height
 
+	if c.renderImage != nil {
+		c.renderImage.Dispose()
+		c.renderImage = nil
+	}
+	if c.w != c.viewW || c.h != c.viewH {
+		c.renderImage = ebiten.NewImage(c.w, c.h)
+	}
+
 	// creating level slices based on screen size
+	c.forceRecast = true
 	c.levels = c.createLevels(c.mapObj.NumLevels())
+	c.glassLvls = c.createGlassLevels(c.mapObj.NumLevels())
+	c.shortWallLvls = c.createShortWallLevels(c.mapObj.NumLevels())
+	c.decalLvls = c.createDecalLevels(c.mapObj.NumLevels())
 	c.slices = makeSlices(c.texSize, c.texSize, 0, 0)
+	if c.floorLvl != nil {
+		c.floorLvl.image.Dispose()
+	}
 	c.floorLvl = c.createFloorLevel()
 
 	// set zbuffer based on screen width
 	c.zBuffer = make([]float64, width)
+	c.columns = make([]ColumnInfo, width)
+	c.glassDepth = make([][]float64, maxGlassLayers)
+	for i := range c.glassDepth {
+		c.glassDepth[i] = make([]float64, width)
+	}
+	c.shortWallDepth = make([][]float64, maxShortWalls)
+	c.shortWallTop = make([][]int, maxShortWalls)
+	for i := range c.shortWallDepth {
+		c.shortWallDepth[i] = make([]float64, width)
+		c.shortWallTop[i] = make([]int, width)
+	}
+
+	// sprite levels are sized to the previous screen width until next raycast
+	if c.spriteLvls != nil {
+		c.clearAllSpriteLevels()
+	}
+
+	// pitch is relative to the view height
+	_, pitchAngle := c.storedAngles()
+	c.SetPitchAngle(pitchAngle)
 }
 
 func (c *Camera) ViewSize() (int, int) {
-	return c.w, c.h
+	return c.viewW, c.viewH
 }
 
-// SetFovAngle sets the FOV angle (degrees) and depth
-func (c *Camera) SetFovAngle(fovDegrees, fovDepth float64) {
-	c.fovAngle = geom.Radians(fovDegrees)
-	c.fovDepth = fovDepth
+// SetRenderScale sets the ratio of the raycasted view size to the viewport size,
+// values below 1.0 raycast at a lower resolution that is scaled up when drawn to improve performance
+func (c *Camera) SetRenderScale(scale float64) {
+	if scale <= 0 {
+		return
+	}
+
+	c.renderScale = scale
+	c.SetViewSize(c.viewW, c.viewH)
+}
+
+func (c *Camera) RenderScale() float64 {
+	return c.renderScale
+}
+
+// toViewX converts a screen X coordinate of the viewport to the raycasted view
+func (c *Camera) toViewX(screenX int) int {
+	if c.viewW == c.w {
+		return screenX
+	}
+	return screenX * c.w / c.viewW
+}
+
+// toScreenX converts an X coordinate of the raycasted view to the first screen X coordinate of the viewport
+// that toViewX converts back to it, so screen ranges converted from view ranges agree with toViewX
+func (c *Camera) toScreenX(viewX int) int {
+	return scaleCeil(viewX, c.viewW, c.w)
+}
+
+// toScreenY converts a Y coordinate of the raycasted view to the first screen Y coordinate of the viewport
+// that is drawn from it
+func (c *Camera) toScreenY(viewY int) int {
+	return scaleCeil(viewY, c.viewH, c.h)
+}
+
+// scaleCeil returns v * num / den rounded up, for a positive den
+func scaleCeil(v, num, den int) int {
+	if num == den {
+		return v
+	}
+	n := v * num
+	q := n / den
+	if n%den > 0 {
+		q++
+	}
+	return q
+}
 
-	var headingAngle float64 = 0
-	if c.dir != nil {
-		headingAngle = c.getAngleFromVec(c.dir)
+// SetFovAngle sets the FOV angle (degrees) and depth, the angle is clamped between 1 and 170 degrees
+// and a depth that is not positive is ignored
+func (c *Camera) SetFovAngle(fovDegrees, fovDepth float64) {
+	c.fovAngle = geom.Radians(geom.Clamp(fovDegrees, minFovAngle, maxFovAngle))
+	if fovDepth > 0 {
+		c.fovDepth = fovDepth
 	}
-	c.dir = c.getVecForAngle(headingAngle)
-	c.plane = c.getVecForFov(c.dir)
+
+	// recompute dir and plane vectors from the canonical heading angle
+	headingAngle, pitchAngle := c.storedAngles()
+	cameraDir := c.getVecForAngle(headingAngle)
+	cameraPlane := c.getVecForFov(cameraDir)
+	c.poseMu.Lock()
+	c.dir, c.plane = cameraDir, cameraPlane
+	c.poseMu.Unlock()
+
+	// pitch is relative to the FOV depth
+	c.SetPitchAngle(pitchAngle)
+}
+
+// SetFovDepth sets the FOV depth while keeping the current FOV angle, for zoom and perspective effects
+func (c *Camera) SetFovDepth(fovDepth float64) {
+	c.SetFovAngle(c.FovAngle(), fovDepth)
 }
 
 func (c *Camera) FovAngle() float64 {
@@ -187,6 +499,55 @@ func (c *Camera) SetSkyTexture(sky *ebiten.Image) {
 	c.sky = sky
 }
 
+// SetSkyScrollFactor sets how fast the sky box texture scrolls horizontally with the camera heading,
+// where 1.0 wraps the sky texture once around a full turn (0 for static sky)
+func (c *Camera) SetSkyScrollFactor(factor float64) {
+	c.skyScrollFactor = factor
+}
+
+// SetBackgroundColor sets the color filling the view where no sky, floor, or wall texture is drawn
+// (e.g. a solid sky color when no sky texture is set)
+func (c *Camera) SetBackgroundColor(backgroundColor color.RGBA) {
+	c.backgroundColor = backgroundColor
+}
+
+// SetSkyTilt sets how much of the skybox texture is revealed vertically as the camera pitches up,
+// where 1.0 reveals the top of the sky texture when looking fully up (0 to always stretch the whole sky to fit)
+func (c *Camera) SetSkyTilt(tilt float64) {
+	c.skyTilt = math.Max(tilt, 0)
+}
+
+// SetCeilingTexture sets the repeating ceiling texture (nil to only render the sky box texture).
+// Not used if the TextureHandler implements CeilingTextureHandler.
+func (c *Camera) SetCeilingTexture(ceiling *image.RGBA) {
+	c.ceiling = ceiling
+	c.forceRecast = true
+}
+
+// ceilingTextureAt returns the ceiling texture at the given map coordinates
+func (c *Camera) ceilingTextureAt(x, y int) *image.RGBA {
+	if ceilingTex, ok := c.tex.(CeilingTextureHandler); ok {
+		return ceilingTex.CeilingTextureAt(x, y)
+	}
+	return c.ceiling
+}
+
+// SetTextureFilter sets the filter used when drawing wall, floor, sky, and sprite textures
+// (ebiten.FilterNearest for the classic pixelated look, ebiten.FilterLinear for smoothing)
+func (c *Camera) SetTextureFilter(filter ebiten.Filter) {
+	c.textureFilter = filter
+}
+
+// SetFloorTextureScale sets the number of map cells the floor and ceiling textures span before repeating
+// (1.0 to repeat every cell)
+func (c *Camera) SetFloorTextureScale(scale float64) {
+	if scale <= 0 {
+		return
+	}
+	c.floorTexScale = scale
+	c.forceRecast = true
+}
+
 // SetRenderDistance sets maximum distance to render raycasted objects (-1 for practically inf)
 func (c *Camera) SetRenderDistance(distance float64) {
 	if distance < 0 {
@@ -194,30 +555,181 @@ func (c *Camera) SetRenderDistance(distance float64) {
 	} else {
 		c.renderDistance = distance
 	}
+	c.forceRecast = true
+}
+
+// SetMaxConcurrent sets the maximum number of concurrent tasks used to cast each level and to cast sprites.
+// Floor and ceiling casting happens within the same tasks as the first level so it is also bounded.
+func (c *Camera) SetMaxConcurrent(max int) {
+	if max < 1 {
+		return
+	}
+	c.maxConcurrent = max
+}
+
+// SetProjection sets the mapping of screen columns to ray directions,
+// ProjectionCylindrical reduces stretching at the screen edges for wide FOV (default ProjectionPlanar)
+func (c *Camera) SetProjection(projection Projection) {
+	c.projection = projection
+	c.forceRecast = true
+}
+
+// SetFisheyeCorrection sets the amount of fisheye correction for walls and sprites, where 1.0 projects
+// by perpendicular distance (no fisheye) and 0.0 by the real distance for a curved fisheye look
+func (c *Camera) SetFisheyeCorrection(amount float64) {
+	c.fisheyeCorrection = geom.Clamp(amount, 0, 1)
+	c.forceRecast = true
+}
+
+// SetSpriteLOD sets the distance beyond which sprites are cast in stripes two columns wide,
+// halving the work for distant sprites at a small cost of horizontal detail (-1 to disable)
+func (c *Camera) SetSpriteLOD(distance float64) {
+	c.spriteLOD = distance
+}
+
+// SetSpriteDepthBias sets the distance subtracted from sprite depth when compared against walls,
+// so sprites placed right against a wall are drawn in front of it instead of being clipped (0 to disable)
+func (c *Camera) SetSpriteDepthBias(bias float64) {
+	c.spriteDepthBias = bias
+}
+
+// SetSkipStaticRecast sets whether casting of walls, floor, and ceiling is skipped during updates
+// when the camera pose has not changed since they were last cast, only casting sprites (e.g. turn-based games).
+// Camera setters that affect them recast on the next update, ForceRecast needs to be called after changes
+// the camera cannot detect: the cells of the map, textures returned by the TextureHandler, or fields of an added Light.
+func (c *Camera) SetSkipStaticRecast(skip bool) {
+	c.skipStaticRecast = skip
+	c.forceRecast = true
+}
+
+// ForceRecast makes the next update cast walls, floor, and ceiling even if the camera pose has not changed
+func (c *Camera) ForceRecast() {
+	c.forceRecast = true
+}
+
+// SetConcurrency sets whether levels and sprites are cast concurrently,
+// false to cast serially for reproducible output when testing or debugging
+func (c *Camera) SetConcurrency(concurrent bool) {
+	c.concurrent = concurrent
 }
 
 // SetLightFalloff sets value that simulates torch light, as if player was carrying a radial light.
 // Lower values make torch dimmer.
 func (c *Camera) SetLightFalloff(falloff float64) {
 	c.lightFalloff = falloff
+	c.forceRecast = true
+}
+
+// SetSideShading sets the amount that walls along the X-axis side are darkened
+// to differentiate between walls of a corner (0 to disable)
+func (c *Camera) SetSideShading(diff int) {
+	c.sideShading = diff
+	c.forceRecast = true
 }
 
 // SetGlobalIllumination sets illumination value for whole level (sun brightness)
 func (c *Camera) SetGlobalIllumination(illumination float64) {
-	c.globalIllumination = illumination
+	c.SetGlobalIlluminationRGB(illumination, illumination, illumination)
+}
+
+// SetGlobalIlluminationRGB sets illumination value for whole level (sun brightness) for each color channel,
+// allowing warmer or cooler colored ambient light
+func (c *Camera) SetGlobalIlluminationRGB(r, g, b float64) {
+	c.globalIllumination = lightRGB{R: r, G: g, B: b}
+	c.forceRecast = true
+}
+
+// SetLevelLighting sets the light falloff and illumination value for a specific level (e.g. a dim basement),
+// used instead of the camera light falloff and global illumination for walls and sprites on that level
+func (c *Camera) SetLevelLighting(levelNum int, falloff, illumination float64) {
+	if c.levelLighting == nil {
+		c.levelLighting = make(map[int]levelLighting)
+	}
+	c.levelLighting[levelNum] = levelLighting{
+		falloff:      falloff,
+		illumination: lightRGB{R: illumination, G: illumination, B: illumination},
+	}
+	c.forceRecast = true
+}
+
+// ClearLevelLighting removes the lighting override for a specific level so it uses the camera defaults
+func (c *Camera) ClearLevelLighting(levelNum int) {
+	delete(c.levelLighting, levelNum)
+	c.forceRecast = true
 }
 
 // SetLightRGB sets the min/max color tinting of the textures when fully shadowed (min) or lighted (max)
 func (c *Camera) SetLightRGB(min, max color.NRGBA) {
 	c.minLightRGB = min
 	c.maxLightRGB = max
+	c.forceRecast = true
+}
+
+// SetFog sets the color that raycasted objects blend towards starting at the start distance,
+// until fully obscured at the end distance (-1 end distance to disable fog)
+func (c *Camera) SetFog(fogColor color.RGBA, start, end float64) {
+	c.fogRGBA = fogColor
+	c.fogStart = start
+	c.fogEnd = end
+	c.forceRecast = true
+}
+
+// getFogAmount returns how much a raycasted object at the given distance from the camera
+// is blended towards the fog color, from 0 (no fog) to 1 (fully obscured)
+func (c *Camera) getFogAmount(distance float64) float64 {
+	if c.fogEnd < 0 || distance <= c.fogStart {
+		return 0
+	}
+	if distance >= c.fogEnd || c.fogEnd <= c.fogStart {
+		return 1
+	}
+	return (distance - c.fogStart) / (c.fogEnd - c.fogStart)
+}
+
+// getLightingRGBA returns the tint of a raycasted object at the given distance from the camera and map position
+// on the level, clamped within the min/max light color tinting
+func (c *Camera) getLightingRGBA(distance, mapPosX, mapPosY float64, levelNum int) color.RGBA {
+	falloff, illumination := c.lightFalloff, c.globalIllumination
+	if lvlLighting, ok := c.levelLighting[levelNum]; ok {
+		falloff, illumination = lvlLighting.falloff, lvlLighting.illumination
+	}
+
+	shadowDepth := math.Sqrt(distance)*falloff + c.pulseLight
+	lighting := lightRGB{
+		R: shadowDepth + illumination.R,
+		G: shadowDepth + illumination.G,
+		B: shadowDepth + illumination.B,
+	}
+
+	for _, light := range c.lights {
+		illumination := light.illuminationAt(mapPosX, mapPosY)
+		lighting.R += illumination.R
+		lighting.G += illumination.G
+		lighting.B += illumination.B
+	}
+
+	return color.RGBA{
+		R: byte(geom.ClampInt(int(255+lighting.R), int(c.minLightRGB.R), int(c.maxLightRGB.R))),
+		G: byte(geom.ClampInt(int(255+lighting.G), int(c.minLightRGB.G), int(c.maxLightRGB.G))),
+		B: byte(geom.ClampInt(int(255+lighting.B), int(c.minLightRGB.B), int(c.maxLightRGB.B))),
+		A: 255,
+	}
 }
 
 // Update - updates the camera view
 func (c *Camera) Update(sprites []Sprite) {
-	// reset convergence point
-	c.convergenceDistance = -1
-	c.convergencePoint = nil
+	if c.closed {
+		return
+	}
+
+	c.resetConvergence()
+
+	// decay light pulse
+	c.pulseLight = 0
+	if c.pulseRemaining > 0 {
+		c.pulseLight = c.pulseIntensity * float64(c.pulseRemaining) / float64(c.pulseDuration)
+		c.pulseRemaining--
+	}
 
 	if len(sprites) != len(c.sprites) {
 		// sprite buffer may need to be increased in size
@@ -231,28 +743,173 @@ func (c *Camera) Update(sprites []Sprite) {
 	c.raycast()
 }
 
+// recast raycasts again with the sprites from the last update so the next Draw is correct,
+// without decaying the light pulse as an update would
+func (c *Camera) recast() {
+	if c.closed {
+		return
+	}
+	c.resetConvergence()
+	c.clearAllSpriteLevels()
+	c.raycast()
+}
+
+// resetConvergence clears the convergence point before raycasting
+func (c *Camera) resetConvergence() {
+	c.convergenceMu.Lock()
+	c.convergenceDistance = -1
+	c.convergencePoint = nil
+	c.convergenceMu.Unlock()
+}
+
+// Close disposes the images owned by the camera and releases its render buffers,
+// safe to call more than once. Further updates and draws of the camera are no-ops.
+func (c *Camera) Close() {
+	if c.closed {
+		return
+	}
+	c.closed = true
+
+	if c.renderImage != nil {
+		c.renderImage.Dispose()
+		c.renderImage = nil
+	}
+	if c.floorLvl != nil {
+		c.floorLvl.image.Dispose()
+		c.floorLvl = nil
+	}
+
+	c.levels = nil
+	c.glassLvls = nil
+	c.shortWallLvls = nil
+	c.decalLvls = nil
+	c.slices = nil
+	c.spriteLvls = nil
+	c.spriteLvlCache = nil
+}
+
+// AddSprite adds a sprite to the set of sprites managed by the camera, cast during UpdateManaged
+func (c *Camera) AddSprite(sprite Sprite) {
+	c.managedSprites = append(c.managedSprites, sprite)
+}
+
+// RemoveSprite removes a sprite that was previously added to the set of sprites managed by the camera
+func (c *Camera) RemoveSprite(sprite Sprite) {
+	for i, s := range c.managedSprites {
+		if s == sprite {
+			// copied to a new slice, the sprites from the last update may share the managed sprites array
+			managedSprites := make([]Sprite, 0, len(c.managedSprites)-1)
+			managedSprites = append(managedSprites, c.managedSprites[:i]...)
+			c.managedSprites = append(managedSprites, c.managedSprites[i+1:]...)
+			return
+		}
+	}
+}
+
+// ClearSprites removes all sprites managed by the camera
+func (c *Camera) ClearSprites() {
+	c.managedSprites = nil
+}
+
+// UpdateManaged - updates the camera view with the sprites managed by the camera,
+// instead of a sprite slice provided by the game each update
+func (c *Camera) UpdateManaged() {
+	c.Update(c.managedSprites)
+}
+
 func (c *Camera) raycast() {
 	var wg sync.WaitGroup
 
-	// cast level
-	numLevels := c.mapObj.NumLevels()
-	for i := 0; i < numLevels; i++ {
-		c.asyncCastLevel(i, &wg)
-	}
+	// cast from a snapshot of the camera pose
+	c.snapshotPose()
 
-	wg.Wait()
+	if c.skipStaticRecast && !c.forceRecast && c.pose == c.castPose && c.pulseLight == c.castPulseLight {
+		// walls, floor, and ceiling are unchanged from the last cast, only sprites need casting
+		c.convergenceMu.Lock()
+		c.convergenceDistance = c.wallConvergenceDistance
+		c.convergencePoint = c.wallConvergencePoint
+		c.convergenceMu.Unlock()
+	} else {
+		// clear floor and ceiling pixels from the previous raycast
+		c.floorLvl.clear()
+
+		// cast level
+		numLevels := c.mapObj.NumLevels()
+		for i := 0; i < numLevels; i++ {
+			c.asyncCastLevel(i, &wg)
+		}
+
+		wg.Wait()
+
+		c.forceRecast = false
+		c.castPose = c.pose
+		c.castPulseLight = c.pulseLight
+		c.convergenceMu.Lock()
+		c.wallConvergenceDistance = c.convergenceDistance
+		c.wallConvergencePoint = c.convergencePoint
+		c.convergenceMu.Unlock()
+	}
 
 	//SPRITE CASTING
 	numSprites := len(c.sprites)
-	c.spriteOrder = make([]int, numSprites)
-	c.spriteDistance = make([]float64, numSprites)
-	//sort sprites from far to close
+	if cap(c.spriteOrder) < numSprites {
+		c.spriteOrder = make([]int, numSprites)
+		c.spriteDistance = make([]float64, numSprites)
+		c.spriteDepth = make([]float64, numSprites)
+		c.spriteRenderOrder = make([]int, numSprites)
+		c.spriteOrdIndex = make([]int, numSprites)
+	}
+	c.spriteOrder = c.spriteOrder[:numSprites]
+	c.spriteOrdIndex = c.spriteOrdIndex[:numSprites]
+	c.spriteDistance = c.spriteDistance[:numSprites]
+	c.spriteDepth = c.spriteDepth[:numSprites]
+	c.spriteRenderOrder = c.spriteRenderOrder[:numSprites]
+
+	if c.spriteIndex == nil {
+		c.spriteIndex = make(map[Sprite]int, numSprites)
+	}
+	for sprite := range c.spriteIndex {
+		delete(c.spriteIndex, sprite)
+	}
+
+	//cull sprites that cannot be on screen before sorting
+	numCast := 0
+	c.spritesCulled = 0
 	for i := 0; i < numSprites; i++ {
 		sprite := c.sprites[i]
-		c.spriteOrder[i] = i
-		c.spriteDistance[i] = math.Sqrt(math.Pow(c.pos.X-sprite.Pos().X, 2) + math.Pow(c.pos.Y-sprite.Pos().Y, 2))
+		if isSpriteComparable(sprite) {
+			if _, ok := c.spriteIndex[sprite]; !ok {
+				c.spriteIndex[sprite] = i
+			}
+		}
+		spriteDist := c.pose.pos.Distance(sprite.Pos())
+		c.spriteOrdIndex[i] = -1
+		if c.isSpriteCulled(sprite, spriteDist) {
+			sprite.SetScreenRect(nil)
+			c.spritesCulled++
+			continue
+		}
+		c.spriteOrder[numCast] = i
+		c.spriteDistance[numCast] = spriteDist
+		c.spriteRenderOrder[numCast] = 0
+		if orderedSprite, ok := sprite.(OrderedSprite); ok {
+			c.spriteRenderOrder[numCast] = orderedSprite.RenderOrder()
+		}
+		numCast++
+	}
+	numSprites = numCast
+	c.spriteOrder = c.spriteOrder[:numSprites]
+	c.spriteDistance = c.spriteDistance[:numSprites]
+	c.spriteDepth = c.spriteDepth[:numSprites]
+	c.spriteRenderOrder = c.spriteRenderOrder[:numSprites]
+
+	//sort sprites from far to close
+	combSort(c.spriteOrder, c.spriteDistance, c.spriteRenderOrder, numSprites)
+
+	// index sorted order of sprites for lookups after the raycast
+	for i := 0; i < numSprites; i++ {
+		c.spriteOrdIndex[c.spriteOrder[i]] = i
 	}
-	combSort(c.spriteOrder, c.spriteDistance, numSprites)
 
 	//after sorting the sprites, do the projection and draw them
 	c.asyncCastSprites(numSprites, &wg)
@@ -260,13 +917,37 @@ func (c *Camera) raycast() {
 	wg.Wait()
 }
 
+// snapshotPose copies the current camera position and orientation for use while raycasting and drawing
+func (c *Camera) snapshotPose() {
+	c.poseMu.Lock()
+	defer c.poseMu.Unlock()
+
+	c.pose = cameraPose{
+		pos:          *c.pos,
+		dir:          *c.dir,
+		plane:        *c.plane,
+		posZ:         c.posZ,
+		camZ:         c.camZ,
+		headingAngle: c.headingAngle,
+		pitchAngle:   c.pitchAngle,
+		pitch:        c.pitch,
+	}
+}
+
 func (c *Camera) asyncCastLevel(levelNum int, wg *sync.WaitGroup) {
 	rMap := c.mapObj.Level(levelNum)
-	stride := c.w / 100
-	if stride < 1 {
-		stride = 1
+
+	if !c.concurrent {
+		for x := 0; x < c.w; x++ {
+			c.castLevel(x, rMap, c.levels[levelNum], levelNum)
+		}
+		return
 	}
 
+	// cast columns in batches so the number of tasks stays within the max concurrency,
+	// the floor and ceiling of each column are cast in the same batch as the first level
+	stride := getConcurrentStride(c.w, c.maxConcurrent)
+
 	for i := 0; i < c.w; i += stride {
 		wg.Add(1)
 
@@ -289,228 +970,131 @@ func (c *Camera) asyncCastSprites(numSprites int, wg *sync.WaitGroup) {
 	defer wg.Done()
 	wg.Add(1)
 
-	stride := numSprites / 32
-	if stride < 1 {
-		stride = 1
+	if !c.concurrent {
+		for s := 0; s < numSprites; s++ {
+			c.castSprite(s)
+		}
+		return
 	}
 
-	for i := 0; i < numSprites; i++ {
-		wg.Add(1)
+	// cast sprites from a pool of workers within the max concurrency pulling sprite indices from a channel,
+	// so a few close sprites covering many columns do not hold up a whole batch of sprites
+	numWorkers := geom.MinInt(c.maxConcurrent, numSprites)
+	spriteIndices := make(chan int, numSprites)
+	for s := 0; s < numSprites; s++ {
+		spriteIndices <- s
+	}
+	close(spriteIndices)
 
-		go func(start int) {
-			end := start + stride
-			if end > numSprites {
-				end = numSprites
-			}
+	for i := 0; i < numWorkers; i++ {
+		wg.Add(1)
 
-			for s := start; s < end; s++ {
+		go func() {
+			for s := range spriteIndices {
 				c.castSprite(s)
 			}
 
 			wg.Done()
-		}(i)
+		}()
+	}
+}
+
+// getConcurrentStride returns the size of each batch needed to split the number of items
+// into at most maxConcurrent batches
+func getConcurrentStride(numItems, maxConcurrent int) int {
+	stride := (numItems + maxConcurrent - 1) / maxConcurrent
+	if stride < 1 {
+		stride = 1
 	}
+	return stride
 }
 
 // credit : Raycast loop and setting up of vectors for matrix calculations
 // courtesy - http://lodev.org/cgtutor/raycasting.html
 func (c *Camera) castLevel(x int, grid [][]int, lvl *level, levelNum int) {
-	var _cts, _sv []*image.Rectangle
-	var _st []*color.RGBA
-
-	_cts = lvl.Cts
-	_sv = lvl.Sv
-	_st = lvl.St
-
 	//calculate ray position and direction
-	cameraX := 2.0*float64(x)/float64(c.w) - 1.0 //x-coordinate in camera space
-	rayDirX := c.dir.X + c.plane.X*cameraX
-	rayDirY := c.dir.Y + c.plane.Y*cameraX
+	rayDirX, rayDirY := c.getRayDir(x)
 
 	//--rays start at camera position--//
-	rayPosX := c.pos.X
-	rayPosY := c.pos.Y
-
-	//which box of the map we're in
-	mapX := int(rayPosX)
-	mapY := int(rayPosY)
-
-	//length of ray from current position to next x or y-side
-	var sideDistX float64
-	var sideDistY float64
+	rayPosX := c.pose.pos.X
+	rayPosY := c.pose.pos.Y
 
-	//length of ray from one x or y-side to next x or y-side
-	deltaDistX := math.Abs(1 / rayDirX)
-	deltaDistY := math.Abs(1 / rayDirY)
-	var perpWallDist float64
+	//perform DDA to find the wall hit by the ray, and the glass walls it passed through and short walls it saw over
+	var glass glassHits
+	var shortWalls shortWallHits
+	mapX, mapY, side, hit, perpWallDist, wallX := c.castRay(rayPosX, rayPosY, rayDirX, rayDirY, grid, levelNum, &glass, &shortWalls, levelNum == 0)
 
-	//what direction to step in x or y-direction (either +1 or -1)
-	var stepX int
-	var stepY int
+	hitInMap := hit == 1 && mapX >= 0 && mapY >= 0 && mapX < c.mapWidth && mapY < c.mapHeight
 
-	hit := 0   //was there a wall hit?
-	side := -1 //was a NS or a EW wall hit?
-
-	//calculate step and initial sideDist
-	if rayDirX < 0 {
-		stepX = -1
-		sideDistX = (rayPosX - float64(mapX)) * deltaDistX
-	} else {
-		stepX = 1
-		sideDistX = (float64(mapX) + 1.0 - rayPosX) * deltaDistX
+	//--scale the wall up from its base for variable height walls, so the ceiling is cast down to its top--//
+	wallHeight := 1.0
+	if hitInMap {
+		wallHeight = c.getWallHeight(mapX, mapY, levelNum)
 	}
 
-	if rayDirY < 0 {
-		stepY = -1
-		sideDistY = (rayPosY - float64(mapY)) * deltaDistY
-	} else {
-		stepY = 1
-		sideDistY = (float64(mapY) + 1.0 - rayPosY) * deltaDistY
-	}
-
-	//perform DDA
-	for hit == 0 {
-		//jump to next map square, OR in x-direction, OR in y-direction
-		if sideDistX < sideDistY {
-			sideDistX += deltaDistX
-			mapX += stepX
-			side = 0
-		} else {
-			sideDistY += deltaDistY
-			mapY += stepY
-			side = 1
-		}
-
-		//Calculate distance of perpendicular ray (oblique distance will give fisheye effect!)
-		if side == 0 {
-			perpWallDist = sideDistX - deltaDistX
-		} else {
-			perpWallDist = sideDistY - deltaDistY
-		}
-
-		//Check if ray has hit a wall
-		if mapX >= 0 && mapY >= 0 && mapX < c.mapWidth && mapY < c.mapHeight {
-			if perpWallDist > c.renderDistance {
-				// hit render distance bounds
-				hit = 2
-			} else if perpWallDist <= c.renderDistance && grid[mapX][mapY] > 0 {
-				// only render walls within render distance
-				hit = 1
-			}
-		} else {
-			//hit grid boundary
-			hit = 2
-		}
-	}
-
-	//Calculate height of line to draw on screen
-	lineHeight := int(float64(c.h) / perpWallDist)
-
 	//calculate lowest and highest pixel to fill in current stripe
-	drawStart := (-lineHeight/2 + c.h/2) + c.pitch + int(c.camZ/perpWallDist) - lineHeight*levelNum
-	drawEnd := drawStart + lineHeight
-
-	//--due to modern way of drawing using quads this is removed to avoid glitches at the edges--//
-	// if drawStart < 0 { drawStart = 0 }
-	// if drawEnd >= c.h { drawEnd = c.h - 1 }
-
-	//calculate value of wallX
-	var wallX float64 //where exactly the wall/boundary was hit
-	if side == 0 {
-		wallX = rayPosY + perpWallDist*rayDirY
-	} else {
-		wallX = rayPosX + perpWallDist*rayDirX
-	}
-	wallX -= math.Floor(wallX)
+	drawStart, drawEnd := c.getWallDrawRows(perpWallDist, rayDirX, rayDirY, levelNum, wallHeight)
 
 	//texturing calculations
 	var texture *ebiten.Image
-	if hit == 1 && mapX >= 0 && mapY >= 0 && mapX < c.mapWidth && mapY < c.mapHeight {
+	if hitInMap {
 		texture = c.tex.TextureAt(mapX, mapY, levelNum, side)
 	}
 
-	c.levels[levelNum].CurrTex[x] = texture
-
-	if texture != nil {
-		//x coordinate on the texture
-		texX := int(wallX * float64(c.texSize))
-		if side == 0 && rayDirX > 0 {
-			texX = c.texSize - texX - 1
+	lvl.CurrTex[x] = nil
+	for _, decalLayers := range c.decalLvls[levelNum] {
+		for _, decalLvl := range decalLayers {
+			decalLvl.CurrTex[x] = nil
 		}
+	}
 
-		if side == 1 && rayDirY < 0 {
-			texX = c.texSize - texX - 1
-		}
+	if texture != nil {
+		texX := c.castWallSlice(x, lvl, texture, side, drawStart, drawEnd, perpWallDist, wallX, rayDirX, rayDirY, levelNum)
 
-		//--set current texture slice to be slice x--//
-		_cts[x] = c.slices[texX]
-
-		//--set height of slice--//
-		_sv[x].Min.Y = drawStart
-
-		//--set draw start of slice--//
-		_sv[x].Max.Y = drawEnd
-
-		//// LIGHTING ////
-		//--distance based dimming of light--//
-		shadowDepth := math.Sqrt(perpWallDist) * c.lightFalloff
-		_st[x] = &color.RGBA{255, 255, 255, 255}
-		_st[x].R = byte(geom.ClampInt(int(float64(_st[x].R)+shadowDepth+c.globalIllumination), int(c.minLightRGB.R), int(c.maxLightRGB.R)))
-		_st[x].G = byte(geom.ClampInt(int(float64(_st[x].G)+shadowDepth+c.globalIllumination), int(c.minLightRGB.G), int(c.maxLightRGB.G)))
-		_st[x].B = byte(geom.ClampInt(int(float64(_st[x].B)+shadowDepth+c.globalIllumination), int(c.minLightRGB.B), int(c.maxLightRGB.B)))
-
-		//--add a bit of tint to differentiate between walls of a corner--//
-		if side == 0 {
-			wallDiff := 12
-			_st[x].R = byte(geom.ClampInt(int(_st[x].R)-wallDiff, 0, 255))
-			_st[x].G = byte(geom.ClampInt(int(_st[x].G)-wallDiff, 0, 255))
-			_st[x].B = byte(geom.ClampInt(int(_st[x].B)-wallDiff, 0, 255))
-		}
+		//--wall decals drawn over the slice--//
+		c.castDecal(x, mapX, mapY, levelNum, side, texX, drawStart, drawEnd, lvl, c.decalLvls[levelNum][0])
 	}
 
 	// determine if is convergence point that hit a wall
 	convergenceCol, convergenceRow := c.w/2-1, c.h/2-1
 	if x == convergenceCol && drawStart <= convergenceRow && convergenceRow <= drawEnd {
-		// use pitch angle and perpendicular distance (adjusted for fov zoom) to find Z point of convergence
-		convergencePerpDist := perpWallDist * c.fovDepth
-		convergenceLine3d := geom3d.Line3dFromBaseAngle(c.pos.X, c.pos.Y, c.posZ, c.headingAngle, c.pitchAngle, convergencePerpDist)
-		convergenceDistance := convergenceLine3d.Distance()
-
-		if c.convergenceDistance == -1 || convergenceDistance < c.convergenceDistance {
-			c.convergenceDistance = convergenceDistance
-			c.convergencePoint = &geom3d.Vector3{X: convergenceLine3d.X2, Y: convergenceLine3d.Y2, Z: convergenceLine3d.Z2}
-		}
+		c.updateConvergence(perpWallDist)
+	}
+
+	//// SHORT WALL CASTING ////
+	for i, shortWallLvl := range c.shortWallLvls[levelNum] {
+		c.castShortWall(x, i, &shortWalls, shortWallLvl, levelNum, rayDirX, rayDirY)
+	}
+
+	//// GLASS CASTING ////
+	for i, glassLvl := range c.glassLvls[levelNum] {
+		c.castGlass(x, i, &glass, &shortWalls, glassLvl, levelNum, rayDirX, rayDirY)
 	}
 
 	//SET THE ZBUFFER FOR THE SPRITE CASTING
 	if levelNum == 0 {
 		// for now only rendering sprites on first level
 		c.zBuffer[x] = perpWallDist //perpendicular distance is used
+
+		c.columns[x] = ColumnInfo{
+			MapX:      mapX,
+			MapY:      mapY,
+			Side:      side,
+			Distance:  perpWallDist,
+			DrawStart: drawStart,
+			DrawEnd:   drawEnd,
+			Texture:   texture,
+		}
 	}
 
 	//// FLOOR CASTING ////
 	if levelNum == 0 {
 		// for now only rendering floor on first level
-		if drawEnd < 0 {
-			drawEnd = c.h //becomes < 0 when the integer overflows
-		}
+		floorStart := geom.ClampInt(drawEnd, 0, c.h)
 
-		var floorXWall, floorYWall float64
-
-		//4 different wall directions possible
-		if side == 0 && rayDirX > 0 {
-			floorXWall = float64(mapX)
-			floorYWall = float64(mapY) + wallX
-		} else if side == 0 && rayDirX < 0 {
-			floorXWall = float64(mapX) + 1.0
-			floorYWall = float64(mapY) + wallX
-		} else if side == 1 && rayDirY > 0 {
-			floorXWall = float64(mapX) + wallX
-			floorYWall = float64(mapY)
-		} else {
-			floorXWall = float64(mapX) + wallX
-			floorYWall = float64(mapY) + 1.0
-		}
+		//map position where the ray hit the wall (may be inset within the cell for thin walls)
+		floorXWall := rayPosX + perpWallDist*rayDirX
+		floorYWall := rayPosY + perpWallDist*rayDirY
 
 		var distWall, distPlayer, currentDist float64
 
@@ -518,9 +1102,9 @@ func (c *Camera) castLevel(x int, grid [][]int, lvl *level, levelNum int) {
 		distPlayer = 0.0
 
 		//draw the floor from drawEnd to the bottom of the screen
-		for y := drawEnd; y < c.h; y++ {
-			currentDist = (float64(c.h) + (2.0 * c.camZ)) / (2.0*float64(y-c.pitch) - float64(c.h))
-			if currentDist > c.renderDistance {
+		for y := floorStart; y < c.h; y++ {
+			currentDist = (float64(c.h) + (2.0 * c.pose.camZ)) / (2.0*float64(y-c.pose.pitch) - float64(c.h))
+			if currentDist < 0 || currentDist > c.renderDistance {
 				continue
 			}
 
@@ -535,15 +1119,7 @@ func (c *Camera) castLevel(x int, grid [][]int, lvl *level, levelNum int) {
 			}
 
 			if x == convergenceCol && y == convergenceRow {
-				// use pitch angle and perpendicular distance (adjusted for fov zoom) to find Z point of convergence
-				convergencePerpDist := currentDist * c.fovDepth
-				convergenceLine3d := geom3d.Line3dFromBaseAngle(c.pos.X, c.pos.Y, c.posZ, c.headingAngle, c.pitchAngle, convergencePerpDist)
-				convergenceDistance := convergenceLine3d.Distance()
-
-				if c.convergenceDistance == 0 || convergenceDistance < c.convergenceDistance {
-					c.convergenceDistance = convergenceDistance
-					c.convergencePoint = &geom3d.Vector3{X: convergenceLine3d.X2, Y: convergenceLine3d.Y2, Z: convergenceLine3d.Z2}
-				}
+				c.updateConvergence(currentDist)
 			}
 
 			//floor texture for map coordinate being rendered
@@ -552,91 +1128,345 @@ func (c *Camera) castLevel(x int, grid [][]int, lvl *level, levelNum int) {
 				continue
 			}
 
-			floorTexX := int(currentFloorX*float64(c.texSize)) % c.texSize
-			floorTexY := int(currentFloorY*float64(c.texSize)) % c.texSize
+			c.castHorizontalPixel(x, y, floorTex, currentFloorX, currentFloorY, currentDist)
+		}
+
+		//// CEILING CASTING ////
+		// for now only rendering ceiling on first level, draw from top of the screen to drawStart.
+		// Short walls in front of the wall are drawn over the ceiling, the floor, and the wall behind them.
+		ceilingEnd := geom.ClampInt(drawStart, 0, c.h)
+		for y := 0; y < ceilingEnd; y++ {
+			currentDist = (float64(c.h) - (2.0 * c.pose.camZ)) / (float64(c.h) - 2.0*float64(y-c.pose.pitch))
+			if currentDist < 0 || currentDist > c.renderDistance {
+				continue
+			}
+
+			weight := (currentDist - distPlayer) / (distWall - distPlayer)
+
+			currentCeilingX := weight*floorXWall + (1.0-weight)*rayPosX
+			currentCeilingY := weight*floorYWall + (1.0-weight)*rayPosY
 
-			// buffer[y][x] = (texture[3][texWidth * floorTexY + floorTexX] >> 1) & 8355711;
-			// the same vertical slice method cannot be used for floor rendering
-			// floorTexNum := 0
-			// floorTex := c.floorLvl.texRGBA[floorTexNum]
+			// do not look up ceiling texture if X/Y is outside of map bounds
+			if currentCeilingX < 0 || currentCeilingY < 0 || int(currentCeilingX) >= c.mapWidth || int(currentCeilingY) >= c.mapHeight {
+				continue
+			}
 
-			//pixel := floorTex.RGBAAt(floorTexX, floorTexY)
-			pxOffset := floorTex.PixOffset(floorTexX, floorTexY)
-			if pxOffset < 0 {
+			//ceiling texture for map coordinate being rendered, falls back to sky when nil
+			ceilingTex := c.ceilingTextureAt(int(currentCeilingX), int(currentCeilingY))
+			if ceilingTex == nil {
 				continue
 			}
-			pixel := color.RGBA{floorTex.Pix[pxOffset],
-				floorTex.Pix[pxOffset+1],
-				floorTex.Pix[pxOffset+2],
-				floorTex.Pix[pxOffset+3]}
-
-			// lighting
-			pixelSt := &color.RGBA{255, 255, 255, 255}
-			shadowDepth := math.Sqrt(currentDist) * c.lightFalloff
-			pixelSt.R = byte(geom.ClampInt(int(float64(pixelSt.R)+shadowDepth+c.globalIllumination), int(c.minLightRGB.R), int(c.maxLightRGB.R)))
-			pixelSt.G = byte(geom.ClampInt(int(float64(pixelSt.G)+shadowDepth+c.globalIllumination), int(c.minLightRGB.G), int(c.maxLightRGB.G)))
-			pixelSt.B = byte(geom.ClampInt(int(float64(pixelSt.B)+shadowDepth+c.globalIllumination), int(c.minLightRGB.B), int(c.maxLightRGB.B)))
-			pixel.R = uint8(float64(pixel.R) * float64(pixelSt.R) / 256)
-			pixel.G = uint8(float64(pixel.G) * float64(pixelSt.G) / 256)
-			pixel.B = uint8(float64(pixel.B) * float64(pixelSt.B) / 256)
-
-			//c.horLvl.HorBuffer.SetRGBA(x, y, pixel)
-			pxOffset = c.floorLvl.horBuffer.PixOffset(x, y)
-			c.floorLvl.horBuffer.Pix[pxOffset] = pixel.R
-			c.floorLvl.horBuffer.Pix[pxOffset+1] = pixel.G
-			c.floorLvl.horBuffer.Pix[pxOffset+2] = pixel.B
-			c.floorLvl.horBuffer.Pix[pxOffset+3] = pixel.A
+
+			if x == convergenceCol && y == convergenceRow {
+				c.updateConvergence(currentDist)
+			}
+
+			c.castHorizontalPixel(x, y, ceilingTex, currentCeilingX, currentCeilingY, currentDist)
+		}
+	}
+}
+
+// getWallHeight returns the height of the wall at the map coordinates and level number
+// relative to the height of a level, from the map when it implements WallHeightMap
+func (c *Camera) getWallHeight(mapX, mapY, levelNum int) float64 {
+	if heightMap, ok := c.mapObj.(WallHeightMap); ok {
+		return heightMap.WallHeight(mapX, mapY, levelNum)
+	}
+	return 1.0
+}
+
+// getWallDrawRows returns the screen rows where the wall slice at the perpendicular distance starts and ends,
+// scaled up from its base by the wall height relative to the height of a level
+func (c *Camera) getWallDrawRows(perpWallDist, rayDirX, rayDirY float64, levelNum int, wallHeight float64) (drawStart, drawEnd int) {
+	//projection distance is kept above a minimum so extremely close walls stay in a representable range
+	projectionDist := math.Max(c.getFisheyeDist(perpWallDist, rayDirX, rayDirY), minProjectionDist)
+
+	//Calculate height of line to draw on screen
+	lineHeight := int(float64(c.h) / projectionDist)
+
+	//--due to modern way of drawing using quads the rows are not clamped to avoid glitches at the edges--//
+	drawStart = (-lineHeight/2 + c.h/2) + c.pose.pitch + int(c.pose.camZ/projectionDist) - lineHeight*levelNum
+	drawEnd = drawStart + lineHeight
+	if wallHeight != 1 {
+		drawStart = drawEnd - int(float64(lineHeight)*wallHeight)
+	}
+	return drawStart, drawEnd
+}
+
+// castWallSlice sets the textured wall slice of the column from drawStart to drawEnd, lighted for the perpendicular
+// distance to where the ray hit the wall, and returns the x coordinate on the texture
+func (c *Camera) castWallSlice(x int, lvl *level, texture *ebiten.Image, side, drawStart, drawEnd int, perpWallDist, wallX, rayDirX, rayDirY float64, levelNum int) int {
+	//x coordinate on the texture
+	texX := c.getWallTexX(wallX, side, rayDirX, rayDirY)
+
+	//--set current texture slice to be slice x--//
+	lvl.Cts[x] = c.slices[texX]
+	lvl.CurrTex[x] = texture
+
+	//--set draw start and end of slice--//
+	lvl.Sv[x].Min.Y = drawStart
+	lvl.Sv[x].Max.Y = drawEnd
+
+	//// LIGHTING ////
+	//--distance based dimming of light--//
+	st := lvl.St[x]
+	*st = c.getLightingRGBA(perpWallDist, c.pose.pos.X+perpWallDist*rayDirX, c.pose.pos.Y+perpWallDist*rayDirY, levelNum)
+	lvl.Sf[x] = c.getFogAmount(perpWallDist)
+
+	//--add a bit of tint to differentiate between walls of a corner--//
+	if side == 0 && c.sideShading != 0 {
+		wallDiff := c.sideShading
+		st.R = byte(geom.ClampInt(int(st.R)-wallDiff, 0, 255))
+		st.G = byte(geom.ClampInt(int(st.G)-wallDiff, 0, 255))
+		st.B = byte(geom.ClampInt(int(st.B)-wallDiff, 0, 255))
+	}
+	return texX
+}
+
+// castShortWall sets the wall slice of the column for the short wall layer, drawn over the walls behind it.
+// Its top row is kept so slices behind it can be clipped above it.
+func (c *Camera) castShortWall(x, layer int, shortWalls *shortWallHits, shortWallLvl *level, levelNum int, rayDirX, rayDirY float64) {
+	shortWallLvl.CurrTex[x] = nil
+	if levelNum == 0 {
+		c.shortWallDepth[layer][x] = -1
+	}
+
+	if layer >= shortWalls.count {
+		return
+	}
+	shortWall := &shortWalls.hits[layer]
+
+	drawStart, drawEnd := c.getWallDrawRows(shortWall.perpWallDist, rayDirX, rayDirY, levelNum,
+		c.getWallHeight(shortWall.mapX, shortWall.mapY, levelNum))
+	shortWall.drawStart = drawStart
+
+	if texture := c.tex.TextureAt(shortWall.mapX, shortWall.mapY, levelNum, shortWall.side); texture != nil {
+		texX := c.castWallSlice(x, shortWallLvl, texture, shortWall.side, drawStart, drawEnd, shortWall.perpWallDist, shortWall.wallX, rayDirX, rayDirY, levelNum)
+
+		//--wall decals drawn over the slice--//
+		c.castDecal(x, shortWall.mapX, shortWall.mapY, levelNum, shortWall.side, texX, drawStart, drawEnd, shortWallLvl, c.decalLvls[levelNum][layer+1])
+	}
+
+	// determine if is convergence point that hit the short wall
+	convergenceCol, convergenceRow := c.w/2-1, c.h/2-1
+	if x == convergenceCol && drawStart <= convergenceRow && convergenceRow <= drawEnd {
+		c.updateConvergence(shortWall.perpWallDist)
+	}
+
+	if levelNum == 0 {
+		c.shortWallDepth[layer][x] = shortWall.perpWallDist
+		c.shortWallTop[layer][x] = drawStart
+	}
+}
+
+// getShortWallClipY returns the screen row that a sprite stripe at the perpendicular depth is clipped to end at
+// in the column, the top of the first level short walls in front of it (the view height if there are none)
+func (c *Camera) getShortWallClipY(x int, depth float64) int {
+	clipY := c.h
+	for layer := range c.shortWallDepth {
+		shortWallDepth := c.shortWallDepth[layer][x]
+		if shortWallDepth < 0 || shortWallDepth >= depth {
+			// short walls are nearest first, the rest are not in front
+			break
 		}
+		clipY = geom.MinInt(clipY, c.shortWallTop[layer][x])
+	}
+	return clipY
+}
+
+// clipSliceBottom clips the slice drawn to the screen rows of sv so it ends at the screen row,
+// cutting the texture rows of cts to match
+func clipSliceBottom(sv, cts *image.Rectangle, endY int) {
+	if endY >= sv.Max.Y || sv.Dy() <= 0 {
+		return
+	}
+	endY = geom.MaxInt(endY, sv.Min.Y)
+	cts.Max.Y = cts.Min.Y + cts.Dy()*(endY-sv.Min.Y)/sv.Dy()
+	sv.Max.Y = endY
+}
+
+// castGlass sets the glass wall slice of the column for the glass layer, drawn with the glass tint over what is behind it
+// and clipped above the short walls in front of it
+func (c *Camera) castGlass(x, layer int, glasses *glassHits, shortWalls *shortWallHits, glassLvl *level, levelNum int, rayDirX, rayDirY float64) {
+	glassLvl.CurrTex[x] = nil
+	if levelNum == 0 {
+		c.glassDepth[layer][x] = -1
+	}
+
+	if layer >= glasses.count {
+		return
+	}
+	glass := &glasses.hits[layer]
+
+	texture := c.tex.TextureAt(glass.mapX, glass.mapY, levelNum, glass.side)
+	if texture == nil {
+		return
+	}
+
+	drawStart, drawEnd := c.getWallDrawRows(glass.perpWallDist, rayDirX, rayDirY, levelNum,
+		c.getWallHeight(glass.mapX, glass.mapY, levelNum))
+	clipY := shortWalls.clipY(glass.perpWallDist, drawEnd)
+	if clipY <= drawStart {
+		// hidden behind short walls
+		return
+	}
+
+	texX := c.getWallTexX(glass.wallX, glass.side, rayDirX, rayDirY)
+	*glassLvl.Cts[x] = *c.slices[texX]
+	glassLvl.Sv[x].Min.Y = drawStart
+	glassLvl.Sv[x].Max.Y = drawEnd
+	clipSliceBottom(glassLvl.Sv[x], glassLvl.Cts[x], clipY)
+	glassLvl.CurrTex[x] = texture
+
+	// lighting multiplied by the glass tint, with the tint alpha as the glass opacity
+	lighting := c.getLightingRGBA(glass.perpWallDist, c.pose.pos.X+glass.perpWallDist*rayDirX, c.pose.pos.Y+glass.perpWallDist*rayDirY, levelNum)
+	*glassLvl.St[x] = color.RGBA{
+		R: byte(int(lighting.R) * int(glass.tint.R) / 255),
+		G: byte(int(lighting.G) * int(glass.tint.G) / 255),
+		B: byte(int(lighting.B) * int(glass.tint.B) / 255),
+		A: glass.tint.A,
+	}
+	glassLvl.Sf[x] = c.getFogAmount(glass.perpWallDist)
+
+	if levelNum == 0 {
+		c.glassDepth[layer][x] = glass.perpWallDist
+	}
+}
+
+// castHorizontalPixel samples the floor or ceiling texture at the given map position
+// and sets the lighted pixel in the horizontal level buffer
+func (c *Camera) castHorizontalPixel(x, y int, tex *image.RGBA, mapPosX, mapPosY, distance float64) {
+	// texture repeats every floorTexScale map cells
+	texX := int(mapPosX/c.floorTexScale*float64(c.texSize)) % c.texSize
+	texY := int(mapPosY/c.floorTexScale*float64(c.texSize)) % c.texSize
+
+	//pixel := tex.RGBAAt(texX, texY)
+	pxOffset := tex.PixOffset(texX, texY)
+	if pxOffset < 0 {
+		return
 	}
+	pixel := color.RGBA{tex.Pix[pxOffset],
+		tex.Pix[pxOffset+1],
+		tex.Pix[pxOffset+2],
+		tex.Pix[pxOffset+3]}
+
+	// lighting
+	pixelSt := c.getLightingRGBA(distance, mapPosX, mapPosY, 0)
+	pixel.R = uint8(float64(pixel.R) * float64(pixelSt.R) / 256)
+	pixel.G = uint8(float64(pixel.G) * float64(pixelSt.G) / 256)
+	pixel.B = uint8(float64(pixel.B) * float64(pixelSt.B) / 256)
+
+	// fog
+	if fogAmount := c.getFogAmount(distance); fogAmount > 0 {
+		pixel.R = uint8(float64(pixel.R)*(1-fogAmount) + float64(c.fogRGBA.R)*fogAmount)
+		pixel.G = uint8(float64(pixel.G)*(1-fogAmount) + float64(c.fogRGBA.G)*fogAmount)
+		pixel.B = uint8(float64(pixel.B)*(1-fogAmount) + float64(c.fogRGBA.B)*fogAmount)
+	}
+
+	//c.floorLvl.horBuffer.SetRGBA(x, y, pixel)
+	pxOffset = c.floorLvl.horBuffer.PixOffset(x, y)
+	c.floorLvl.horBuffer.Pix[pxOffset] = pixel.R
+	c.floorLvl.horBuffer.Pix[pxOffset+1] = pixel.G
+	c.floorLvl.horBuffer.Pix[pxOffset+2] = pixel.B
+	c.floorLvl.horBuffer.Pix[pxOffset+3] = pixel.A
+}
+
+// getFisheyeDist returns the distance used to project an object at the perpendicular distance in the direction
+// from the camera, blended towards the uncorrected distance along that direction when fisheye correction is reduced
+func (c *Camera) getFisheyeDist(perpDist, dirX, dirY float64) float64 {
+	if c.fisheyeCorrection >= 1 {
+		return perpDist
+	}
+
+	// ratio of the distance along the direction to the perpendicular distance in front of the camera
+	dirLength := math.Hypot(dirX, dirY)
+	perpLength := (dirX*c.pose.dir.X + dirY*c.pose.dir.Y) / c.pose.dir.Length()
+	if dirLength == 0 || perpLength <= 0 {
+		return perpDist
+	}
+	fisheyeRatio := dirLength / perpLength
+
+	return perpDist * (1 + (1-c.fisheyeCorrection)*(fisheyeRatio-1))
+}
+
+// getSpriteTransform transforms the sprite position relative to the camera with the inverse camera matrix,
+// where transformY is the depth of the sprite in front of the camera
+func (c *Camera) getSpriteTransform(spriteX, spriteY float64) (transformX, transformY float64) {
+	invDet := 1.0 / (c.pose.plane.X*c.pose.dir.Y - c.pose.dir.X*c.pose.plane.Y) //required for correct matrix multiplication
+
+	transformX = invDet * (c.pose.dir.Y*spriteX - c.pose.dir.X*spriteY)
+	transformY = invDet * (-c.pose.plane.Y*spriteX + c.pose.plane.X*spriteY)
+	return transformX, transformY
+}
+
+// isSpriteCulled returns true if the sprite is beyond render distance, behind the camera,
+// or horizontally outside of the camera view so it does not need to be sorted or cast
+func (c *Camera) isSpriteCulled(sprite Sprite, spriteDist float64) bool {
+	if spriteDist > c.renderDistance {
+		return true
+	}
+
+	transformX, transformY := c.getSpriteTransform(sprite.Pos().X-c.pose.pos.X, sprite.Pos().Y-c.pose.pos.Y)
+	if transformY <= 0 {
+		return true
+	}
+
+	spriteScreenX := c.getScreenX(transformX / transformY)
+	spriteScaleX, _ := getSpriteScale(sprite)
+	spriteHalfWidth := math.Abs(float64(c.h)/transformY) * spriteScaleX / 2
+	return spriteScreenX+spriteHalfWidth < 0 || spriteScreenX-spriteHalfWidth >= float64(c.w)
 }
 
 func (c *Camera) castSprite(spriteOrdIndex int) {
 	// the sprite
 	sprite := c.sprites[c.spriteOrder[spriteOrdIndex]]
-
 	spriteDist := c.spriteDistance[spriteOrdIndex]
-	if spriteDist > c.renderDistance {
-		sprite.SetScreenRect(nil)
-		return
-	}
 
 	// track whether the sprite actually needs to draw
 	renderSprite := false
 
 	//translate sprite position to relative to camera
-	spriteX := sprite.Pos().X - c.pos.X
-	spriteY := sprite.Pos().Y - c.pos.Y
+	spriteX := sprite.Pos().X - c.pose.pos.X
+	spriteY := sprite.Pos().Y - c.pose.pos.Y
 
 	spriteTex := sprite.Texture()
-	spriteTexRect := sprite.TextureRect()
-	spriteTexWidth, spriteTexHeight := spriteTex.Size()
+	var spriteTexRect image.Rectangle
+	if dirSprite, ok := sprite.(DirectionalSprite); ok {
+		relAngle := math.Atan2(-spriteY, -spriteX)
+		spriteTexRect = dirSprite.TextureRectForAngle(relAngle)
+	} else {
+		spriteTexRect = sprite.TextureRect()
+	}
+	// sample within the texture rectangle size, so frames of differing sizes can be packed in a texture atlas
+	spriteTexWidth, spriteTexHeight := spriteTexRect.Dx(), spriteTexRect.Dy()
 
 	//transform sprite with the inverse camera matrix
 	// [ planeX   dirX ] -1                                       [ dirY      -dirX ]
 	// [               ]       =  1/(planeX*dirY-dirX*planeY) *   [                 ]
 	// [ planeY   dirY ]                                          [ -planeY  planeX ]
 
-	invDet := 1.0 / (c.plane.X*c.dir.Y - c.dir.X*c.plane.Y) //required for correct matrix multiplication
+	transformX, transformY := c.getSpriteTransform(spriteX, spriteY)
+	c.spriteDepth[spriteOrdIndex] = transformY
 
-	transformX := invDet * (c.dir.Y*spriteX - c.dir.X*spriteY)
-	transformY := invDet * (-c.plane.Y*spriteX + c.plane.X*spriteY)
+	spriteScreenX := int(c.getScreenX(transformX / transformY))
 
-	spriteScreenX := int(float64(c.w) / 2 * (1 + transformX/transformY))
+	//depth used to project the sprite size, the same as transformY unless fisheye correction is reduced
+	projectionY := c.getFisheyeDist(transformY, spriteX, spriteY)
 
 	//parameters for scaling and translating the sprites
-	spriteScale := sprite.Scale()
+	spriteScaleX, spriteScaleY := getSpriteScale(sprite)
 	spriteAnchor := sprite.VerticalAnchor()
 
-	var uDiv float64 = 1 / spriteScale
-	var vDiv float64 = 1 / spriteScale
-	var vOffset float64 = getAnchorVerticalOffset(spriteAnchor, spriteScale, c.h)
+	var uDiv float64 = 1 / spriteScaleX
+	var vDiv float64 = 1 / spriteScaleY
+	var vOffset float64 = getAnchorVerticalOffset(spriteAnchor, spriteScaleY, c.h)
 
-	var vMove float64 = -sprite.PosZ()*float64(c.h) + vOffset
+	var vMove float64 = -getSpritePosZ(sprite)*float64(c.h) + vOffset
 
-	vMoveScreen := int(vMove/transformY) + c.pitch + int(c.camZ/transformY)
+	vMoveScreen := int(vMove/projectionY) + c.pose.pitch + int(c.pose.camZ/projectionY)
 
 	//calculate height of the sprite on screen
-	spriteHeight := int(math.Abs(float64(c.h)/transformY) / vDiv) //using "transformY" instead of the real distance prevents fisheye
+	spriteHeight := int(math.Abs(float64(c.h)/projectionY) / vDiv) //using "transformY" instead of the real distance prevents fisheye
 
 	//calculate lowest and highest pixel to fill in current stripe
 	drawStartY := -spriteHeight/2 + c.h/2 + vMoveScreen
@@ -649,7 +1479,7 @@ func (c *Camera) castSprite(spriteOrdIndex int) {
 	}
 
 	//calculate width of the sprite
-	spriteWidth := int(math.Abs(float64(c.h)/transformY) / uDiv)
+	spriteWidth := int(math.Abs(float64(c.h)/projectionY) / uDiv)
 
 	drawStartX := -spriteWidth/2 + spriteScreenX
 	drawEndX := spriteWidth/2 + spriteScreenX
@@ -666,6 +1496,28 @@ func (c *Camera) castSprite(spriteOrdIndex int) {
 		return
 	}
 
+	// distance and point light based lighting/shading is the same for the whole sprite
+	spriteLighting := c.getLightingRGBA(transformY, sprite.Pos().X, sprite.Pos().Y, getSpriteLevelNum(sprite))
+
+	// color modulation for the sprite, if any
+	var colorMod *color.RGBA
+	if tintSprite, ok := sprite.(TintedSprite); ok {
+		spriteColorMod := tintSprite.ColorMod()
+		colorMod = &spriteColorMod
+	}
+
+	// opacity of the sprite, if translucent
+	opacity := 1.0
+	if translucentSprite, ok := sprite.(TranslucentSprite); ok {
+		opacity = geom.Clamp(translucentSprite.Opacity(), 0, 1)
+	}
+
+	// whether the texture is sampled mirrored horizontally
+	flipSprite := false
+	if flippedSprite, ok := sprite.(FlippedSprite); ok {
+		flipSprite = flippedSprite.FlipHorizontal()
+	}
+
 	// used to determine if is convergence point that hit a sprite
 	canConverge := sprite.IsFocusable()
 	convergenceCol, convergenceRow := c.w/2-1, c.h/2-1
@@ -677,66 +1529,94 @@ func (c *Camera) castSprite(spriteOrdIndex int) {
 	d = (drawEndY-1-vMoveScreen)*256 - c.h*128 + spriteHeight*128
 	texEndY := ((d * spriteTexHeight) / spriteHeight) / 256
 
-	var spriteSlices []*image.Rectangle
+	// distant sprites are cast in stripes two columns wide when LOD is enabled
+	lodStripes := c.spriteLOD >= 0 && spriteDist > c.spriteLOD
+	stripeWidth := 1
+
+	// sprite depth compared against wall depth, biased towards the camera
+	depthY := transformY - c.spriteDepthBias
 
 	//loop through every vertical stripe of the sprite on screen
-	for stripe := drawStartX; stripe < drawEndX; stripe++ {
+	for stripe := drawStartX; stripe < drawEndX; stripe += stripeWidth {
+		stripeWidth = 1
 		//the conditions in the if are:
 		//1) it's in front of camera plane so you don't see things behind you
 		//2) it's on the screen (left)
 		//3) it's on the screen (right)
 		//4) ZBuffer, with perpendicular distance
-		if transformY > 0 && stripe > 0 && stripe < c.w && transformY < c.zBuffer[stripe] {
+		if transformY > 0 && stripe > 0 && stripe < c.w && depthY < c.zBuffer[stripe] {
+			// short walls in front of the sprite hide it below their top
+			clipY := c.getShortWallClipY(stripe, depthY)
+			if clipY <= drawStartY {
+				continue
+			}
+
+			if lodStripes && stripe+1 < drawEndX && stripe+1 < c.w && depthY < c.zBuffer[stripe+1] &&
+				c.getShortWallClipY(stripe+1, depthY) == clipY {
+				// next column is also visible, let this stripe cover it
+				stripeWidth = 2
+			}
+
 			var spriteLvl *level
 			if !renderSprite {
 				renderSprite = true
 				spriteLvl = c.makeSpriteLevel(spriteOrdIndex)
-				spriteSlices = makeSlices(spriteTexWidth, spriteTexHeight, spriteTexRect.Min.X, spriteTexRect.Min.Y)
 			} else {
 				spriteLvl = c.spriteLvls[spriteOrdIndex]
 			}
 
 			texX := int(256*(stripe-(-spriteWidth/2+spriteScreenX))*spriteTexWidth/spriteWidth) / 256
-			if texX < 0 || texX >= cap(spriteSlices) {
+			if texX < 0 || texX >= spriteTexWidth {
 				continue
 			}
+			if flipSprite {
+				texX = spriteTexWidth - 1 - texX
+			}
 
-			if canConverge && stripe == convergenceCol && drawStartY <= convergenceRow && convergenceRow <= drawEndY {
-				// use pitch angle and perpendicular distance (adjusted for fov zoom) to find Z point of convergence
-				convergencePerpDist := spriteDist * c.fovDepth
-				convergenceLine3d := geom3d.Line3dFromBaseAngle(c.pos.X, c.pos.Y, c.posZ, c.headingAngle, c.pitchAngle, convergencePerpDist)
-				convergenceDistance := convergenceLine3d.Distance()
-
-				if c.convergenceDistance == -1 || convergenceDistance < c.convergenceDistance {
-					c.convergenceDistance = convergenceDistance
-					c.convergencePoint = &geom3d.Vector3{X: convergenceLine3d.X2, Y: convergenceLine3d.Y2, Z: convergenceLine3d.Z2}
-				}
+			if canConverge && stripe == convergenceCol && drawStartY <= convergenceRow && convergenceRow <= geom.MinInt(drawEndY, clipY) {
+				c.updateConvergence(spriteDist)
 			}
 
 			//--set current texture slice--//
-			spriteLvl.Cts[stripe] = spriteSlices[texX]
-			spriteLvl.Cts[stripe].Min.Y = spriteTexRect.Min.Y + texStartY
-			spriteLvl.Cts[stripe].Max.Y = spriteTexRect.Min.Y + texEndY
+			*spriteLvl.Cts[stripe] = image.Rect(
+				spriteTexRect.Min.X+texX, spriteTexRect.Min.Y+texStartY,
+				spriteTexRect.Min.X+texX+1, spriteTexRect.Min.Y+texEndY,
+			)
 
 			spriteLvl.CurrTex[stripe] = spriteTex
 
 			//--set draw start and height of slice--//
 			spriteLvl.Sv[stripe].Min.Y = drawStartY
 			spriteLvl.Sv[stripe].Max.Y = drawEndY
+			spriteLvl.Sv[stripe].Max.X = stripe + stripeWidth
+			clipSliceBottom(spriteLvl.Sv[stripe], spriteLvl.Cts[stripe], clipY)
 
 			//// LIGHTING ////
 			// distance based lighting/shading
-			shadowDepth := math.Sqrt(transformY) * c.lightFalloff
-			spriteLvl.St[stripe] = &color.RGBA{255, 255, 255, 255}
-			spriteLvl.St[stripe].R = byte(geom.ClampInt(int(float64(spriteLvl.St[stripe].R)+shadowDepth+c.globalIllumination), int(c.minLightRGB.R), int(c.maxLightRGB.R)))
-			spriteLvl.St[stripe].G = byte(geom.ClampInt(int(float64(spriteLvl.St[stripe].G)+shadowDepth+c.globalIllumination), int(c.minLightRGB.G), int(c.maxLightRGB.G)))
-			spriteLvl.St[stripe].B = byte(geom.ClampInt(int(float64(spriteLvl.St[stripe].B)+shadowDepth+c.globalIllumination), int(c.minLightRGB.B), int(c.maxLightRGB.B)))
+			*spriteLvl.St[stripe] = spriteLighting
+			if colorMod != nil {
+				spriteLvl.St[stripe].R = uint8(uint16(spriteLvl.St[stripe].R) * uint16(colorMod.R) / 255)
+				spriteLvl.St[stripe].G = uint8(uint16(spriteLvl.St[stripe].G) * uint16(colorMod.G) / 255)
+				spriteLvl.St[stripe].B = uint8(uint16(spriteLvl.St[stripe].B) * uint16(colorMod.B) / 255)
+				spriteLvl.St[stripe].A = uint8(uint16(spriteLvl.St[stripe].A) * uint16(colorMod.A) / 255)
+			}
+			if opacity < 1 {
+				spriteLvl.St[stripe].A = uint8(float64(spriteLvl.St[stripe].A) * opacity)
+			}
+			spriteLvl.Sf[stripe] = c.getFogAmount(transformY)
 		}
 	}
 
 	if renderSprite {
 		// store raycasted sprite x/y view bounds so they can be retrieved by consumers
 		spriteCastRect := image.Rect(drawStartX, drawStartY, drawEndX, drawEndY)
+		if c.renderImage != nil {
+			// scale to viewport screen coordinates
+			spriteCastRect = image.Rect(
+				c.toScreenX(drawStartX), c.toScreenY(drawStartY),
+				c.toScreenX(drawEndX), c.toScreenY(drawEndY),
+			)
+		}
 		sprite.SetScreenRect(&spriteCastRect)
 	} else {
 		c.clearSpriteLevel(spriteOrdIndex)
@@ -765,13 +1645,38 @@ func (c *Camera) createLevels(numLevels int) []*level {
 		levelArr[i] = new(level)
 		levelArr[i].Sv = sliceView(c.w, c.h)
 		levelArr[i].Cts = make([]*image.Rectangle, c.w)
-		levelArr[i].St = make([]*color.RGBA, c.w)
+		levelArr[i].St = makeTints(c.w)
+		levelArr[i].Sf = make([]float64, c.w)
 		levelArr[i].CurrTex = make([]*ebiten.Image, c.w)
 	}
 
 	return levelArr
 }
 
+// createGlassLevels creates level slices for each glass layer of each level,
+// with a source rectangle for each column since glass slices are clipped above short walls
+func (c *Camera) createGlassLevels(numLevels int) [][]*level {
+	glassLvls := make([][]*level, numLevels)
+	for i := range glassLvls {
+		glassLvls[i] = c.createLevels(maxGlassLayers)
+		for _, glassLvl := range glassLvls[i] {
+			for x := range glassLvl.Cts {
+				glassLvl.Cts[x] = &image.Rectangle{}
+			}
+		}
+	}
+	return glassLvls
+}
+
+// createShortWallLevels creates level slices for each short wall seen over in front of each level
+func (c *Camera) createShortWallLevels(numLevels int) [][]*level {
+	shortWallLvls := make([][]*level, numLevels)
+	for i := range shortWallLvls {
+		shortWallLvls[i] = c.createLevels(maxShortWalls)
+	}
+	return shortWallLvls
+}
+
 // creates floor slices for raycasting floor
 func (c *Camera) createFloorLevel() *horLevel {
 	horizontalLevel := new(horLevel)
@@ -796,14 +1701,30 @@ func (c *Camera) updateSpriteLevels(spriteCapacity int) {
 		spriteCapacity = capacity
 	}
 	c.spriteLvls = make([]*level, spriteCapacity)
+
+	// keep previously allocated sprite levels to be reused
+	spriteLvlCache := make([]*level, spriteCapacity)
+	copy(spriteLvlCache, c.spriteLvlCache)
+	c.spriteLvlCache = spriteLvlCache
 }
 
 func (c *Camera) makeSpriteLevel(spriteOrdIndex int) *level {
-	spriteLvl := new(level)
-	spriteLvl.Sv = sliceView(c.w, c.h)
-	spriteLvl.Cts = make([]*image.Rectangle, c.w)
-	spriteLvl.St = make([]*color.RGBA, c.w)
-	spriteLvl.CurrTex = make([]*ebiten.Image, c.w)
+	spriteLvl := c.spriteLvlCache[spriteOrdIndex]
+	if spriteLvl == nil || len(spriteLvl.CurrTex) != c.w {
+		spriteLvl = new(level)
+		spriteLvl.Sv = sliceView(c.w, c.h)
+		spriteLvl.Cts = sliceView(c.w, c.h)
+		spriteLvl.St = makeTints(c.w)
+		spriteLvl.Sf = make([]float64, c.w)
+		spriteLvl.CurrTex = make([]*ebiten.Image, c.w)
+
+		c.spriteLvlCache[spriteOrdIndex] = spriteLvl
+	} else {
+		// reusing sprite level from a previous raycast, clear its textures
+		for x := range spriteLvl.CurrTex {
+			spriteLvl.CurrTex[x] = nil
+		}
+	}
 
 	c.spriteLvls[spriteOrdIndex] = spriteLvl
 
@@ -820,8 +1741,10 @@ func (c *Camera) clearSpriteLevel(spriteOrdIndex int) {
 	c.spriteLvls[spriteOrdIndex] = nil
 }
 
-// sort algorithm
-func combSort(order []int, dist []float64, amount int) {
+// sort algorithm, sorts from farthest to closest distance.
+// Ties in distance are broken by render order so higher render order sprites draw on top,
+// then by the original order index so sprites at equal distance keep a consistent draw order between frames.
+func combSort(order []int, dist []float64, renderOrder []int, amount int) {
 	gap := amount
 	swapped := false
 	for gap > 1 || swapped {
@@ -837,9 +1760,11 @@ func combSort(order []int, dist []float64, amount int) {
 		swapped = false
 		for i := 0; i < amount-gap; i++ {
 			j := i + gap
-			if dist[i] < dist[j] {
+			if dist[i] < dist[j] || (dist[i] == dist[j] && (renderOrder[i] > renderOrder[j] ||
+				(renderOrder[i] == renderOrder[j] && order[i] > order[j]))) {
 				// std::swap implementation for go:
 				dist[i], dist[j] = dist[j], dist[i]
+				renderOrder[i], renderOrder[j] = renderOrder[j], renderOrder[i]
 				order[i], order[j] = order[j], order[i]
 				swapped = true
 			}
@@ -847,18 +1772,111 @@ func combSort(order []int, dist []float64, amount int) {
 	}
 }
 
-// Set camera position vector
+// Set camera position vector, the camera keeps a copy so the given vector can be reused by the caller
 func (c *Camera) SetPosition(pos *geom.Vector2) {
-	c.pos = pos
+	c.poseMu.Lock()
+	prevX, prevY := int(math.Floor(c.pos.X)), int(math.Floor(c.pos.Y))
+	c.pos = pos.Copy()
+	c.poseMu.Unlock()
+
+	if c.onEnterCell != nil {
+		cellX, cellY := int(math.Floor(pos.X)), int(math.Floor(pos.Y))
+		if cellX != prevX || cellY != prevY {
+			c.onEnterCell(cellX, cellY)
+		}
+	}
+}
+
+// SetOnEnterCell sets the function called by SetPosition when the camera moves into a different map cell
+// (e.g. for trigger zones, teleporters, and pickups), nil to remove it
+func (c *Camera) SetOnEnterCell(onEnterCell func(x, y int)) {
+	c.onEnterCell = onEnterCell
 }
 
-// Get camera position vector
+// Get a copy of the camera position vector, use SetPosition to move the camera
 func (c *Camera) GetPosition() *geom.Vector2 {
-	return c.pos
+	c.poseMu.Lock()
+	defer c.poseMu.Unlock()
+
+	return c.pos.Copy()
+}
+
+// CurrentCell returns the x, y map coordinates of the cell the camera is in
+func (c *Camera) CurrentCell() (x, y int) {
+	x, y, _ = c.CellAt(c.GetPosition())
+	return x, y
+}
+
+// CellAt returns the x, y map coordinates of the cell at the position, and its value on the first level
+// (-1 if the position is outside of the map)
+func (c *Camera) CellAt(pos *geom.Vector2) (x, y int, value int) {
+	x, y = int(math.Floor(pos.X)), int(math.Floor(pos.Y))
+	if x < 0 || y < 0 || x >= c.mapWidth || y >= c.mapHeight {
+		return x, y, -1
+	}
+	return x, y, c.mapObj.Level(0)[x][y]
+}
+
+// SetStartPosition sets the camera position that is restored on Reset
+func (c *Camera) SetStartPosition(pos *geom.Vector2) {
+	c.startPos = pos.Copy()
+}
+
+// Reset restores the camera to the start position with default vertical position, heading, pitch, pitch limits,
+// and FOV, then raycasts again with the sprites from the last update so the next Draw is correct.
+// The function set by SetOnEnterCell is not called for the start position.
+func (c *Camera) Reset() {
+	c.poseMu.Lock()
+	c.pos = c.startPos.Copy()
+	c.posZ = 0.0
+	c.camZ = 0.0
+	c.poseMu.Unlock()
+	c.SetHeadingAngle(0)
+	c.SetFovAngle(defaultFovAngle, defaultFovDepth)
+	c.SetPitchLimits(-geom.HalfPi, geom.HalfPi)
+	c.SetPitchAngle(0)
+
+	c.recast()
+}
+
+// GetMap returns the map the camera is raycasting
+func (c *Camera) GetMap() Map {
+	return c.mapObj
+}
+
+// SetMap switches the map the camera is raycasting without reallocating its view buffers (e.g. level transitions),
+// then raycasts again with the sprites from the last update so the next Draw is correct.
+// Explored cells and wall decals of the previous map are cleared, the camera position is not changed.
+func (c *Camera) SetMap(mapObj Map) {
+	if c.closed {
+		return
+	}
+
+	numLevels := c.mapObj.NumLevels()
+
+	c.mapObj = mapObj
+	firstLevel := mapObj.Level(0)
+	c.mapWidth = len(firstLevel)
+	c.mapHeight = len(firstLevel[0])
+	c.explored = make([]uint32, c.mapWidth*c.mapHeight)
+	c.ClearWallDecals()
+	c.forceRecast = true
+
+	if mapObj.NumLevels() != numLevels {
+		c.levels = c.createLevels(mapObj.NumLevels())
+		c.glassLvls = c.createGlassLevels(mapObj.NumLevels())
+		c.shortWallLvls = c.createShortWallLevels(mapObj.NumLevels())
+		c.decalLvls = c.createDecalLevels(mapObj.NumLevels())
+	}
+
+	c.recast()
 }
 
 // Set camera Z-plane position
 func (c *Camera) SetPositionZ(gridPosZ float64) {
+	c.poseMu.Lock()
+	defer c.poseMu.Unlock()
+
 	// convert grid position to camera position
 	c.posZ = gridPosZ
 	c.camZ = (gridPosZ - 0.5) * float64(c.h)
@@ -866,23 +1884,158 @@ func (c *Camera) SetPositionZ(gridPosZ float64) {
 
 // Get camera Z-plane position
 func (c *Camera) GetPositionZ() float64 {
+	c.poseMu.Lock()
+	defer c.poseMu.Unlock()
+
 	return c.posZ
 }
 
 // Set camera direction and plane vectors from given heading angle
 func (c *Camera) SetHeadingAngle(headingAngle float64) {
-	c.headingAngle = headingAngle
 	cameraDir := c.getVecForAngle(headingAngle)
+	cameraPlane := c.getVecForFov(cameraDir)
+
+	c.poseMu.Lock()
+	defer c.poseMu.Unlock()
+
+	c.headingAngle = headingAngle
 	c.dir = cameraDir
-	c.plane = c.getVecForFov(cameraDir)
+	c.plane = cameraPlane
+}
+
+// RotateCamera rotates the camera direction and plane vectors by the angle (in radians) from the current heading,
+// renormalized to the FOV depth and angle so repeated rotations do not drift in length
+func (c *Camera) RotateCamera(angle float64) {
+	c.poseMu.Lock()
+	defer c.poseMu.Unlock()
+
+	cos, sin := math.Cos(angle), math.Sin(angle)
+	dir := &geom.Vector2{X: c.dir.X*cos - c.dir.Y*sin, Y: c.dir.X*sin + c.dir.Y*cos}
+	plane := &geom.Vector2{X: c.plane.X*cos - c.plane.Y*sin, Y: c.plane.X*sin + c.plane.Y*cos}
+
+	// keep the plane perpendicular to the direction, then restore their lengths for the FOV
+	dir.Normalize()
+	dot := plane.X*dir.X + plane.Y*dir.Y
+	plane.X -= dot * dir.X
+	plane.Y -= dot * dir.Y
+
+	c.dir = dir.Scale(c.fovDepth)
+	c.plane = plane.Normalize().Scale(c.fovDepth * math.Tan(c.fovAngle/2))
+	c.headingAngle += angle
+}
+
+// GetHeadingAngle returns the camera heading angle derived from the direction vector,
+// normalized to the range [0, geom.Pi2) radians
+func (c *Camera) GetHeadingAngle() float64 {
+	c.poseMu.Lock()
+	defer c.poseMu.Unlock()
+
+	headingAngle := math.Mod(c.getAngleFromVec(c.dir), geom.Pi2)
+	if headingAngle < 0 {
+		headingAngle += geom.Pi2
+	}
+	return headingAngle
+}
+
+// GetDirection returns a copy of the camera direction vector
+func (c *Camera) GetDirection() *geom.Vector2 {
+	c.poseMu.Lock()
+	defer c.poseMu.Unlock()
+
+	return c.dir.Copy()
+}
+
+// GetPlane returns a copy of the camera plane vector
+func (c *Camera) GetPlane() *geom.Vector2 {
+	c.poseMu.Lock()
+	defer c.poseMu.Unlock()
+
+	return c.plane.Copy()
+}
+
+// FacingVector returns the normalized camera heading direction
+func (c *Camera) FacingVector() *geom.Vector2 {
+	return c.GetDirection().Normalize()
+}
+
+// SpawnPointAhead returns the map position at the distance in front of the camera along its heading
+// (e.g. to spawn projectiles)
+func (c *Camera) SpawnPointAhead(distance float64) *geom.Vector2 {
+	return c.FacingVector().Scale(distance).Add(c.GetPosition())
+}
+
+// GetPitchAngle returns the camera pitch angle (in radians) derived from the current pitch view,
+// the inverse of the mapping used by SetPitchAngle
+func (c *Camera) GetPitchAngle() float64 {
+	c.poseMu.Lock()
+	defer c.poseMu.Unlock()
+
+	if c.h == 0 {
+		return 0
+	}
+	return math.Atan(float64(c.pitch) / (float64(c.h) * c.fovDepth))
 }
 
-// Set camera pitch view from given pitch angle
+// storedAngles returns the heading and pitch angles (in radians) last set on the camera
+func (c *Camera) storedAngles() (headingAngle, pitchAngle float64) {
+	c.poseMu.Lock()
+	defer c.poseMu.Unlock()
+
+	return c.headingAngle, c.pitchAngle
+}
+
+// Set camera pitch view from given pitch angle, limited by the camera pitch limits
 func (c *Camera) SetPitchAngle(pitchAngle float64) {
-	c.pitchAngle = pitchAngle
+	c.poseMu.Lock()
+	defer c.poseMu.Unlock()
+
+	pitchAngle = geom.Clamp(pitchAngle, c.minPitchAngle, c.maxPitchAngle)
 	cameraPitch := geom.GetOppositeTriangleLeg(pitchAngle, float64(c.h)*c.fovDepth)
 	// clamping it since looking down or up too far causes floor texture glitches and wall warping
-	c.pitch = geom.ClampInt(int(cameraPitch), -c.h/2, int(float64(c.h)*c.fovDepth))
+	pitch := geom.ClampInt(int(cameraPitch), -c.h/2, int(float64(c.h)*c.fovDepth))
+
+	if pitch != int(cameraPitch) && c.h > 0 {
+		// keep pitch angle consistent with the clamped pitch view so the convergence point stays accurate
+		pitchAngle = math.Atan(float64(pitch) / (float64(c.h) * c.fovDepth))
+	}
+
+	c.pitchAngle = pitchAngle
+	c.pitch = pitch
+}
+
+// SetPitchDegrees sets camera pitch view from given pitch angle in degrees, limited by the camera pitch limits
+func (c *Camera) SetPitchDegrees(pitchDegrees float64) {
+	c.SetPitchAngle(geom.Radians(pitchDegrees))
+}
+
+// PitchCameraDegrees pitches the camera view up (positive) or down (negative) by the angle in degrees,
+// limited by the camera pitch limits
+func (c *Camera) PitchCameraDegrees(deltaDegrees float64) {
+	_, pitchAngle := c.storedAngles()
+	c.SetPitchAngle(pitchAngle + geom.Radians(deltaDegrees))
+}
+
+// SetPitchLimits sets the minimum and maximum camera pitch angle (in radians) allowed by SetPitchAngle
+func (c *Camera) SetPitchLimits(minPitchAngle, maxPitchAngle float64) {
+	if minPitchAngle > maxPitchAngle {
+		minPitchAngle, maxPitchAngle = maxPitchAngle, minPitchAngle
+	}
+	c.poseMu.Lock()
+	c.minPitchAngle = math.Max(minPitchAngle, -geom.HalfPi)
+	c.maxPitchAngle = math.Min(maxPitchAngle, geom.HalfPi)
+	c.poseMu.Unlock()
+
+	// re-apply the current pitch within the new limits
+	_, pitchAngle := c.storedAngles()
+	c.SetPitchAngle(pitchAngle)
+}
+
+// PitchLimits returns the minimum and maximum camera pitch angle (in radians)
+func (c *Camera) PitchLimits() (float64, float64) {
+	c.poseMu.Lock()
+	defer c.poseMu.Unlock()
+
+	return c.minPitchAngle, c.maxPitchAngle
 }
 
 // Get the angle from the dir vectors
@@ -890,32 +2043,122 @@ func (c *Camera) getAngleFromVec(dir *geom.Vector2) float64 {
 	return math.Atan2(dir.Y, dir.X)
 }
 
-// Get the dir vector from angle and fov length
-func (c *Camera) getVecForAngleLength(angle, length float64) *geom.Vector2 {
-	return &geom.Vector2{X: length * math.Cos(angle), Y: length * math.Sin(angle)}
-}
-
 func (c *Camera) getVecForAngle(angle float64) *geom.Vector2 {
 	return &geom.Vector2{X: c.fovDepth * math.Cos(angle), Y: c.fovDepth * math.Sin(angle)}
 }
 
 // Get the plane vector from FOV based on dir vector
 func (c *Camera) getVecForFov(dir *geom.Vector2) *geom.Vector2 {
+	return PlaneForFOV(dir, c.fovAngle)
+}
+
+// PlaneForFOV returns the camera plane vector for the direction vector and FOV angle (in radians).
+// The plane is perpendicular to the direction, with a length of the direction length times the tangent of half the FOV,
+// so the FOV depth (direction length) only scales it (e.g. for building custom camera vectors).
+func PlaneForFOV(dir *geom.Vector2, fovRadians float64) *geom.Vector2 {
 	// get the hypotenuse of half the FOV triangle to calculate the plane vec points
-	angle := c.getAngleFromVec(dir)
-	length := math.Sqrt(math.Pow(dir.X, 2) + math.Pow(dir.Y, 2))
-	hypotenuse := length / math.Cos(c.fovAngle/2)
+	angle := math.Atan2(dir.Y, dir.X)
+	hypotenuse := dir.Length() / math.Cos(fovRadians/2)
+	edge := &geom.Vector2{X: hypotenuse * math.Cos(angle+fovRadians/2), Y: hypotenuse * math.Sin(angle+fovRadians/2)}
 
 	// subtract resulting vector from dir since plane vec is relative to it
-	return dir.Copy().Sub(c.getVecForAngleLength(angle+c.fovAngle/2, hypotenuse))
+	return dir.Copy().Sub(edge)
+}
+
+// FOVForPlane returns the FOV angle (in radians) of the direction and camera plane vectors, the inverse of PlaneForFOV
+func FOVForPlane(dir, plane *geom.Vector2) float64 {
+	return 2 * math.Atan2(plane.Length(), dir.Length())
+}
+
+// updateConvergence keeps the closest point of convergence found at the given perpendicular distance
+// from the center of the camera view, safe to call from concurrently cast levels and sprites
+func (c *Camera) updateConvergence(perpDist float64) {
+	// use pitch angle and perpendicular distance (adjusted for fov zoom) to find Z point of convergence
+	convergencePerpDist := perpDist * c.fovDepth
+	convergenceLine3d := geom3d.Line3dFromBaseAngle(c.pose.pos.X, c.pose.pos.Y, c.pose.posZ, c.pose.headingAngle, c.pose.pitchAngle, convergencePerpDist)
+	convergenceDistance := convergenceLine3d.Distance()
+
+	c.convergenceMu.Lock()
+	defer c.convergenceMu.Unlock()
+	if c.convergenceDistance == -1 || convergenceDistance < c.convergenceDistance {
+		c.convergenceDistance = convergenceDistance
+		c.convergencePoint = &geom3d.Vector3{X: convergenceLine3d.X2, Y: convergenceLine3d.Y2, Z: convergenceLine3d.Z2}
+	}
 }
 
 // Get the distance to the point of convergence raycasted from the center of the camera view
 func (c *Camera) GetConvergenceDistance() float64 {
+	c.convergenceMu.Lock()
+	defer c.convergenceMu.Unlock()
 	return c.convergenceDistance
 }
 
 // Get the 3-Dimensional point of convergence raycasted from the center of the camera view
 func (c *Camera) GetConvergencePoint() *geom3d.Vector3 {
+	c.convergenceMu.Lock()
+	defer c.convergenceMu.Unlock()
 	return c.convergencePoint
 }
+
+// DepthAt returns the perpendicular distance to the wall raycasted at the given screen column
+// during the last update, past any short walls in front of it (-1 if the column is outside of the camera view)
+func (c *Camera) DepthAt(x int) float64 {
+	if x < 0 || x >= c.viewW {
+		return -1
+	}
+	return c.zBuffer[c.toViewX(x)]
+}
+
+// Depths returns a copy of the perpendicular distances to the nearest wall for each raycasted column
+// during the last update (the number of columns depends on the render scale)
+func (c *Camera) Depths() []float64 {
+	depths := make([]float64, len(c.zBuffer))
+	copy(depths, c.zBuffer)
+	return depths
+}
+
+// getSpriteOrdIndex returns the sorted order index of the sprite from the last raycast,
+// -1 if the sprite was not provided in the last update or was culled before casting.
+// Sprites are looked up by value, so sprites of a type that is not comparable are never found.
+func (c *Camera) getSpriteOrdIndex(sprite Sprite) int {
+	if !isSpriteComparable(sprite) {
+		return -1
+	}
+	i, ok := c.spriteIndex[sprite]
+	if !ok {
+		return -1
+	}
+	return c.spriteOrdIndex[i]
+}
+
+// isSpriteComparable returns true if the sprite can be compared by value and used as a map key
+// without panicking (e.g. a pointer, but not a struct with a slice field)
+func isSpriteComparable(sprite Sprite) bool {
+	return sprite != nil && reflect.TypeOf(sprite).Comparable()
+}
+
+// SpriteDistance returns the distance from the camera to the sprite during the last update
+// (-1 if the sprite was not provided in the last update or was culled before casting)
+func (c *Camera) SpriteDistance(sprite Sprite) float64 {
+	spriteOrdIndex := c.getSpriteOrdIndex(sprite)
+	if spriteOrdIndex < 0 {
+		return -1
+	}
+	return c.spriteDistance[spriteOrdIndex]
+}
+
+// SpritesCulled returns the number of sprites that were skipped during the last update
+// for being beyond render distance, behind the camera, or outside of the camera view
+func (c *Camera) SpritesCulled() int {
+	return c.spritesCulled
+}
+
+// IsSpriteVisible returns true if any part of the sprite was rendered on screen during the last update,
+// false if it was off screen, beyond render distance, or completely hidden behind walls
+func (c *Camera) IsSpriteVisible(sprite Sprite) bool {
+	spriteOrdIndex := c.getSpriteOrdIndex(sprite)
+	if spriteOrdIndex < 0 {
+		return false
+	}
+	return c.spriteLvls[spriteOrdIndex] != nil
+}
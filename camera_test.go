@@ -0,0 +1,981 @@
+package raycaster
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"runtime"
+	"sync"
+	"testing"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/harbdog/raycaster-go/geom"
+)
+
+const (
+	testViewWidth  = 64
+	testViewHeight = 48
+	testTexSize    = 16
+)
+
+// testMap is a map from a grid where cell values greater than 0 are walls,
+// with the grid used for each of its levels (a single level if not set)
+type testMap struct {
+	grid      [][]int
+	numLevels int
+}
+
+func (m *testMap) Level(levelNum int) [][]int {
+	return m.grid
+}
+
+func (m *testMap) NumLevels() int {
+	if m.numLevels > 1 {
+		return m.numLevels
+	}
+	return 1
+}
+
+// newTestGrid returns a map grid of the size with walls around the edges
+func newTestGrid(width, height int) [][]int {
+	grid := make([][]int, width)
+	for x := range grid {
+		grid[x] = make([]int, height)
+		for y := range grid[x] {
+			if x == 0 || y == 0 || x == width-1 || y == height-1 {
+				grid[x][y] = 1
+			}
+		}
+	}
+	return grid
+}
+
+// testTextures returns the same wall texture everywhere, and the floor texture when set
+type testTextures struct {
+	wall  *ebiten.Image
+	floor *image.RGBA
+}
+
+func newTestTextures() *testTextures {
+	return &testTextures{wall: ebiten.NewImage(testTexSize, testTexSize)}
+}
+
+func (t *testTextures) TextureAt(x, y, levelNum, side int) *ebiten.Image {
+	return t.wall
+}
+
+func (t *testTextures) FloorTextureAt(x, y int) *image.RGBA {
+	return t.floor
+}
+
+// testSprite is a sprite at a fixed position that records the screen rectangle it was last cast at
+type testSprite struct {
+	pos        *geom.Vector2
+	texture    *ebiten.Image
+	screenRect *image.Rectangle
+}
+
+func newTestSprite(x, y float64, texture *ebiten.Image) *testSprite {
+	return &testSprite{pos: &geom.Vector2{X: x, Y: y}, texture: texture}
+}
+
+func (s *testSprite) Pos() *geom.Vector2               { return s.pos }
+func (s *testSprite) PosZ() float64                    { return 0.5 }
+func (s *testSprite) Scale() float64                   { return 1.0 }
+func (s *testSprite) VerticalAnchor() SpriteAnchor     { return AnchorCenter }
+func (s *testSprite) Texture() *ebiten.Image           { return s.texture }
+func (s *testSprite) TextureRect() image.Rectangle     { return s.texture.Bounds() }
+func (s *testSprite) SetScreenRect(r *image.Rectangle) { s.screenRect = r }
+func (s *testSprite) IsFocusable() bool                { return true }
+
+// newTestCamera returns a camera in the middle of an open map with walls around the edges
+func newTestCamera(t testing.TB, mapWidth, mapHeight int) *Camera {
+	t.Helper()
+	pos := &geom.Vector2{X: float64(mapWidth) / 2, Y: float64(mapHeight) / 2}
+	c, err := NewCameraAt(testViewWidth, testViewHeight, testTexSize, &testMap{grid: newTestGrid(mapWidth, mapHeight)}, newTestTextures(), pos, 0)
+	if err != nil {
+		t.Fatalf("NewCameraAt: %v", err)
+	}
+	return c
+}
+
+func TestUpdateWhileMovingCamera(t *testing.T) {
+	pos := &geom.Vector2{X: 2, Y: 4}
+	c, err := NewCameraAt(testViewWidth, testViewHeight, testTexSize, &testMap{grid: newTestGrid(8, 8), numLevels: 3}, newTestTextures(), pos, 0)
+	if err != nil {
+		t.Fatalf("NewCameraAt: %v", err)
+	}
+
+	// several focusable sprites across the center column, so sprite workers and levels find convergence points together
+	tex := ebiten.NewImage(testTexSize, testTexSize)
+	var sprites []Sprite
+	for i := 0; i < 8; i++ {
+		sprites = append(sprites, newTestSprite(5.5+float64(i%2)/2, 3.5+float64(i)/8, tex))
+	}
+
+	// move the camera from another goroutine while updating, run with -race to detect unguarded pose access
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+
+			c.SetPosition(&geom.Vector2{X: 2 + float64(i%300)/100, Y: 4})
+			c.SetPositionZ(0.5 + float64(i%10)/100)
+			c.SetHeadingAngle(geom.Radians(float64(i % 360)))
+			c.SetPitchAngle(geom.Radians(float64(i%20 - 10)))
+			c.PitchCameraDegrees(float64(i%3 - 1))
+			c.SetPitchLimits(-geom.Radians(float64(30+i%10)), geom.Radians(float64(30+i%10)))
+			c.GetPosition().Scale(1)
+			c.GetPositionZ()
+			c.GetHeadingAngle()
+			c.GetPitchAngle()
+			c.PitchLimits()
+			c.GetConvergenceDistance()
+			c.GetConvergencePoint()
+			c.SpawnPointAhead(1)
+		}
+	}()
+
+	for i := 0; i < 50; i++ {
+		c.Update(sprites)
+	}
+	close(done)
+	wg.Wait()
+}
+
+func TestSetPositionCopiesVector(t *testing.T) {
+	c := newTestCamera(t, 8, 8)
+
+	pos := &geom.Vector2{X: 2.5, Y: 3.5}
+	c.SetPosition(pos)
+	pos.X = 5.5
+	if got := c.GetPosition(); got.X != 2.5 || got.Y != 3.5 {
+		t.Errorf("camera moved with caller vector, got %v", got)
+	}
+
+	c.GetPosition().X = 6.5
+	if got := c.GetPosition(); got.X != 2.5 {
+		t.Errorf("camera moved with returned vector, got %v", got)
+	}
+}
+
+// testSpawnMap is a test map that provides the camera spawn pose
+type testSpawnMap struct {
+	testMap
+	spawnPos     *geom.Vector2
+	spawnHeading float64
+}
+
+func (m *testSpawnMap) SpawnPose() (*geom.Vector2, float64) {
+	return m.spawnPos, m.spawnHeading
+}
+
+func TestNewCameraSpawnPose(t *testing.T) {
+	grid := newTestGrid(8, 8)
+	tests := []struct {
+		name        string
+		mapObj      Map
+		wantPos     geom.Vector2
+		wantHeading float64
+	}{
+		{"unimplemented", &testMap{grid: grid}, geom.Vector2{X: 1, Y: 1}, 0},
+		{"implemented", &testSpawnMap{testMap{grid: grid}, &geom.Vector2{X: 5.5, Y: 3.5}, geom.HalfPi}, geom.Vector2{X: 5.5, Y: 3.5}, geom.HalfPi},
+		{"inside wall", &testSpawnMap{testMap{grid: grid}, &geom.Vector2{X: 0.5, Y: 3.5}, geom.HalfPi}, geom.Vector2{X: 1, Y: 1}, 0},
+		{"outside map", &testSpawnMap{testMap{grid: grid}, &geom.Vector2{X: 9.5, Y: 3.5}, geom.HalfPi}, geom.Vector2{X: 1, Y: 1}, 0},
+		{"nil position", &testSpawnMap{testMap{grid: grid}, nil, geom.HalfPi}, geom.Vector2{X: 1, Y: 1}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCamera(testViewWidth, testViewHeight, testTexSize, tt.mapObj, newTestTextures())
+			if got := c.GetPosition(); *got != tt.wantPos {
+				t.Errorf("position = %v, want %v", *got, tt.wantPos)
+			}
+			if got := c.GetHeadingAngle(); !geom.NearlyEqual(got, tt.wantHeading, 1e-9) {
+				t.Errorf("heading = %v, want %v", got, tt.wantHeading)
+			}
+		})
+	}
+}
+
+func TestCombSortStableForEqualDistances(t *testing.T) {
+	// sprites 1, 2, and 4 are coincident, sprites 0 and 3 are equidistant on either side of the camera
+	dist := []float64{4, 2, 2, 4, 2, 1}
+	renderOrder := make([]int, len(dist))
+	want := []int{0, 3, 1, 2, 4, 5}
+
+	// the same input sorted every frame always gives the same order
+	for frame := 0; frame < 10; frame++ {
+		order := []int{0, 1, 2, 3, 4, 5}
+		frameDist := append([]float64(nil), dist...)
+		combSort(order, frameDist, renderOrder, len(order))
+		for i := range want {
+			if order[i] != want[i] {
+				t.Fatalf("frame %v: order = %v, want %v", frame, order, want)
+			}
+		}
+	}
+
+	// input given in a different order still breaks ties by sprite index
+	order := []int{4, 2, 5, 1, 3, 0}
+	shuffledDist := []float64{2, 2, 1, 2, 4, 4}
+	combSort(order, shuffledDist, make([]int, len(order)), len(order))
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("shuffled order = %v, want %v", order, want)
+		}
+	}
+}
+
+func TestUpdateCoincidentSpritesKeepOrder(t *testing.T) {
+	c := newTestCamera(t, 8, 8)
+	texture := ebiten.NewImage(testTexSize, testTexSize)
+	sprites := []Sprite{
+		newTestSprite(6, 4, texture),
+		newTestSprite(6, 4, texture),
+		newTestSprite(6, 4, texture),
+	}
+
+	for frame := 0; frame < 10; frame++ {
+		c.Update(sprites)
+		for i, sprite := range sprites {
+			if got := c.getSpriteOrdIndex(sprite); got != i {
+				t.Fatalf("frame %v: sprite %v drawn at order %v, want %v", frame, i, got, i)
+			}
+		}
+	}
+}
+
+// testOrderedSprite is a test sprite with a render order
+type testOrderedSprite struct {
+	*testSprite
+	renderOrder int
+}
+
+func (s *testOrderedSprite) RenderOrder() int {
+	return s.renderOrder
+}
+
+func TestCombSortRenderOrder(t *testing.T) {
+	order := []int{0, 1, 2, 3}
+	dist := []float64{2, 2, 2, 3}
+	renderOrder := []int{1, -1, 0, 5}
+	combSort(order, dist, renderOrder, len(order))
+
+	// farthest first regardless of render order, then lower render order drawn first
+	want := []int{3, 1, 2, 0}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("order = %v, want %v", order, want)
+		}
+	}
+}
+
+func TestUpdateStackedSpritesRenderOrder(t *testing.T) {
+	c := newTestCamera(t, 8, 8)
+	texture := ebiten.NewImage(testTexSize, testTexSize)
+	pickup := &testOrderedSprite{newTestSprite(6, 4, texture), 1}
+	aura := &testOrderedSprite{newTestSprite(6, 4, texture), 0}
+
+	// pickup is drawn over its aura regardless of the order the sprites are provided in
+	for _, sprites := range [][]Sprite{{pickup, aura}, {aura, pickup}} {
+		c.Update(sprites)
+		if c.getSpriteOrdIndex(pickup) <= c.getSpriteOrdIndex(aura) {
+			t.Errorf("pickup drawn at order %v, not over aura at order %v", c.getSpriteOrdIndex(pickup), c.getSpriteOrdIndex(aura))
+		}
+	}
+}
+
+func TestUpdateFlushAgainstWall(t *testing.T) {
+	textures := newTestTextures()
+	textures.floor = image.NewRGBA(image.Rect(0, 0, testTexSize, testTexSize))
+	c := NewCamera(testViewWidth, testViewHeight, testTexSize, &testMap{grid: newTestGrid(8, 8)}, textures)
+
+	// largest line height allowed by the projection distance clamp
+	maxLineHeight := int(float64(c.h)/minProjectionDist) + 1
+
+	// edge distances a game keeps the camera from walls when moving, down to touching the wall
+	// (at a distance of 0 the camera is inside the wall cell)
+	for _, edgeDistance := range []float64{0.1, 1e-3, 1e-6, 1e-12, 1e-15} {
+		for _, degrees := range []float64{0, 30, -30} {
+			// wall on the first level starts at X 7.0
+			c.SetPosition(&geom.Vector2{X: 7 - edgeDistance, Y: 4.5})
+			c.SetHeadingAngle(geom.Radians(degrees))
+			c.SetPitchAngle(0)
+			c.Update(nil)
+
+			info := c.ColumnInfo(testViewWidth / 2)
+			if info == nil || info.Texture == nil {
+				t.Fatalf("edge distance %v heading %v: no wall in center column", edgeDistance, degrees)
+			}
+			for x := 0; x < testViewWidth; x++ {
+				info := c.ColumnInfo(x)
+				if info.Texture == nil {
+					continue
+				}
+				lineHeight := info.DrawEnd - info.DrawStart
+				if lineHeight <= 0 || lineHeight > maxLineHeight {
+					t.Fatalf("edge distance %v heading %v column %v: draw rows %v to %v out of range",
+						edgeDistance, degrees, x, info.DrawStart, info.DrawEnd)
+				}
+			}
+		}
+	}
+}
+
+func TestUpdateFloorBelowWallAtEdgeDistance(t *testing.T) {
+	textures := newTestTextures()
+	textures.floor = image.NewRGBA(image.Rect(0, 0, testTexSize, testTexSize))
+	for i := range textures.floor.Pix {
+		textures.floor.Pix[i] = 255
+	}
+	c := NewCamera(testViewWidth, testViewHeight, testTexSize, &testMap{grid: newTestGrid(8, 8)}, textures)
+
+	floorRows := 0
+	for _, edgeDistance := range []float64{0.5, 0.1, 1e-3, 1e-6, 1e-12, 1e-15} {
+		// low to the floor and looking down so the floor below very close walls is in view
+		for _, posZ := range []float64{0.5, 0.01} {
+			for _, pitchAngle := range []float64{0, -geom.HalfPi} {
+				// wall on the first level starts at X 7.0
+				c.SetPosition(&geom.Vector2{X: 7 - edgeDistance, Y: 4.5})
+				c.SetPositionZ(posZ)
+				c.SetHeadingAngle(0)
+				c.SetPitchAngle(pitchAngle)
+				c.Update(nil)
+
+				// every row below the wall is floor
+				for x := 0; x < testViewWidth; x++ {
+					drawEnd := c.ColumnInfo(x).DrawEnd
+					if drawEnd < 0 {
+						t.Fatalf("edge distance %v column %v: wall ends above the view at row %v", edgeDistance, x, drawEnd)
+					}
+					for y := drawEnd; y < testViewHeight; y++ {
+						if c.floorLvl.horBuffer.RGBAAt(x, y).A == 0 {
+							t.Fatalf("edge distance %v position Z %v pitch %v column %v: floor row %v below wall not drawn",
+								edgeDistance, posZ, pitchAngle, x, y)
+						}
+						floorRows++
+					}
+				}
+			}
+		}
+	}
+
+	if floorRows == 0 {
+		t.Error("no floor in view below the wall")
+	}
+}
+
+func TestSetViewSize(t *testing.T) {
+	c := newTestCamera(t, 8, 8)
+	c.SetPosition(&geom.Vector2{X: 4.5, Y: 4.5})
+	c.SetPositionZ(0.75)
+	c.AddWallDecal(7, 4, 0, 0, 0.5, 0.5, 0.5, 0.5, ebiten.NewImage(testTexSize, testTexSize))
+	sprites := []Sprite{newTestSprite(6, 4.5, ebiten.NewImage(testTexSize, testTexSize))}
+	c.Update(sprites)
+	wantDepth := c.DepthAt(testViewWidth / 2)
+
+	// resize up and down, then back to the original size
+	for _, size := range []image.Point{{128, 96}, {32, 24}, {testViewWidth, testViewHeight}} {
+		c.SetViewSize(size.X, size.Y)
+		c.Update(sprites)
+
+		if len(c.zBuffer) != size.X || len(c.columns) != size.X || len(c.glassDepth[0]) != size.X || len(c.shortWallTop[0]) != size.X {
+			t.Errorf("size %v: column buffer lengths %v, %v, %v", size, len(c.zBuffer), len(c.columns), len(c.glassDepth[0]))
+		}
+		for _, lvls := range [][]*level{c.levels, c.glassLvls[0], c.shortWallLvls[0], c.decalLvls[0][0], c.spriteLvls[:1]} {
+			lvl := lvls[0]
+			if len(lvl.Sv) != size.X || len(lvl.Cts) != size.X || len(lvl.St) != size.X || len(lvl.Sf) != size.X || len(lvl.CurrTex) != size.X {
+				t.Fatalf("size %v: level slices not resized", size)
+			}
+		}
+		if got := c.floorLvl.horBuffer.Bounds().Size(); got != size {
+			t.Errorf("size %v: floor buffer size %v", size, got)
+		}
+
+		// vertical camera position is relative to the view height
+		if want := 0.25 * float64(size.Y); !geom.NearlyEqual(c.camZ, want, 1e-9) {
+			t.Errorf("size %v: camZ = %v, want %v", size, c.camZ, want)
+		}
+		if got := c.GetPositionZ(); got != 0.75 {
+			t.Errorf("size %v: position Z = %v, want 0.75", size, got)
+		}
+
+		if got := c.DepthAt(size.X / 2); !geom.NearlyEqual(got, wantDepth, 1e-9) {
+			t.Errorf("size %v: center depth = %v, want %v", size, got, wantDepth)
+		}
+		if got := c.DepthAt(size.X); got != -1 {
+			t.Errorf("size %v: depth outside view = %v, want -1", size, got)
+		}
+	}
+}
+
+func TestPlaneForFOVRoundTrip(t *testing.T) {
+	for _, dir := range []geom.Vector2{{X: 1, Y: 0}, {X: 0, Y: -1}, {X: -0.6, Y: 0.8}, {X: 2.5, Y: 1.5}} {
+		for degrees := minFovAngle; degrees <= maxFovAngle; degrees++ {
+			fov := geom.Radians(degrees)
+			plane := PlaneForFOV(&dir, fov)
+
+			if got := FOVForPlane(&dir, plane); !geom.NearlyEqual(got, fov, 1e-9) {
+				t.Errorf("dir %v fov %v: FOVForPlane = %v degrees", dir, degrees, geom.Degrees(got))
+			}
+			if dot := dir.X*plane.X + dir.Y*plane.Y; math.Abs(dot) > 1e-9*dir.Length()*plane.Length() {
+				t.Errorf("dir %v fov %v: plane %v not perpendicular to dir", dir, degrees, plane)
+			}
+			if want := dir.Length() * math.Tan(fov/2); !geom.NearlyEqual(plane.Length(), want, 1e-9*want) {
+				t.Errorf("dir %v fov %v: plane length = %v, want %v", dir, degrees, plane.Length(), want)
+			}
+		}
+	}
+}
+
+func TestSetFovAngleClamp(t *testing.T) {
+	c := newTestCamera(t, 8, 8)
+
+	tests := []struct {
+		fovDegrees, want float64
+	}{
+		{-10, minFovAngle},
+		{0, minFovAngle},
+		{minFovAngle, minFovAngle},
+		{90, 90},
+		{maxFovAngle, maxFovAngle},
+		{180, maxFovAngle},
+		{720, maxFovAngle},
+	}
+
+	for _, tt := range tests {
+		c.SetFovAngle(tt.fovDegrees, 1)
+		if got := c.FovAngle(); !geom.NearlyEqual(got, tt.want, 1e-9) {
+			t.Errorf("SetFovAngle(%v): FOV angle = %v, want %v", tt.fovDegrees, got, tt.want)
+		}
+		if got := geom.Degrees(FOVForPlane(c.GetDirection(), c.GetPlane())); !geom.NearlyEqual(got, tt.want, 1e-9) {
+			t.Errorf("SetFovAngle(%v): camera vectors FOV = %v, want %v", tt.fovDegrees, got, tt.want)
+		}
+	}
+
+	// a depth that is not positive keeps the current depth
+	c.SetFovAngle(70, 2)
+	c.SetFovAngle(70, 0)
+	c.SetFovAngle(70, -1)
+	if got := c.FovDepth(); got != 2 {
+		t.Errorf("FOV depth = %v, want 2", got)
+	}
+}
+
+func TestRotateBackAndForthReturnsToStart(t *testing.T) {
+	c := newTestCamera(t, 8, 8)
+	c.SetHeadingAngle(geom.Radians(30))
+	startDir, startPlane := c.GetDirection(), c.GetPlane()
+
+	// turn right and back left every update, changing the FOV in between like a zoom effect
+	const r = 0.0123
+	heading := c.GetHeadingAngle()
+	for i := 0; i < 100000; i++ {
+		heading += r
+		c.SetHeadingAngle(heading)
+		heading -= r
+		c.SetHeadingAngle(heading)
+		if i%1000 == 0 {
+			c.SetFovAngle(c.FovAngle(), c.FovDepth())
+		}
+	}
+
+	if got := c.GetDirection(); !got.NearlyEquals(startDir, 1e-9) {
+		t.Errorf("direction = %v, want %v", got, startDir)
+	}
+	if got := c.GetPlane(); !got.NearlyEquals(startPlane, 1e-9) {
+		t.Errorf("plane = %v, want %v", got, startPlane)
+	}
+}
+
+func TestRotateCameraKeepsVectorLengths(t *testing.T) {
+	c := newTestCamera(t, 8, 8)
+	c.SetFovAngle(70, 1.5)
+	wantDir, wantPlane := c.GetDirection().Length(), c.GetPlane().Length()
+
+	const r = 0.0123
+	for i := 0; i < 100000; i++ {
+		c.RotateCamera(r)
+	}
+
+	dir, plane := c.GetDirection(), c.GetPlane()
+	if got := dir.Length(); !geom.NearlyEqual(got, wantDir, 1e-12) {
+		t.Errorf("direction length = %v, want %v", got, wantDir)
+	}
+	if got := plane.Length(); !geom.NearlyEqual(got, wantPlane, 1e-12) {
+		t.Errorf("plane length = %v, want %v", got, wantPlane)
+	}
+	if dot := dir.X*plane.X + dir.Y*plane.Y; math.Abs(dot) > 1e-12 {
+		t.Errorf("plane %v not perpendicular to direction %v", plane, dir)
+	}
+	if got := geom.Degrees(FOVForPlane(dir, plane)); !geom.NearlyEqual(got, 70, 1e-9) {
+		t.Errorf("FOV = %v after rotating, want 70", got)
+	}
+
+	// same heading as setting the total rotation at once
+	c.SetHeadingAngle(100000 * r)
+	if want := c.GetDirection(); !dir.NearlyEquals(want, 1e-6) {
+		t.Errorf("direction = %v after rotating, want %v", dir, want)
+	}
+}
+
+func TestGetHeadingAngleRange(t *testing.T) {
+	c := newTestCamera(t, 8, 8)
+	for degrees := -720.0; degrees <= 720; degrees += 45 {
+		c.SetHeadingAngle(geom.Radians(degrees))
+
+		got := c.GetHeadingAngle()
+		if got < 0 || got >= geom.Pi2 {
+			t.Errorf("%v degrees: heading angle %v outside of [0, %v)", degrees, got, geom.Pi2)
+		}
+
+		// same direction, within the precision of geom.Pi
+		dir := c.GetDirection().Normalize()
+		if gotX, gotY := math.Cos(got), math.Sin(got); math.Hypot(gotX-dir.X, gotY-dir.Y) > 1e-4 {
+			t.Errorf("%v degrees: heading angle %v direction (%v, %v), want %v", degrees, got, gotX, gotY, dir)
+		}
+	}
+}
+
+func TestSetPitchAngleClamp(t *testing.T) {
+	c := newTestCamera(t, 8, 8)
+	minPitch, maxPitch := -c.h/2, int(float64(c.h)*c.fovDepth)
+
+	tests := []struct {
+		name       string
+		pitchAngle float64
+		want       int
+	}{
+		{"level", 0, 0},
+		{"up", geom.Radians(20), int(float64(c.h) * math.Tan(geom.Radians(20)))},
+		{"down", geom.Radians(-20), int(float64(c.h) * math.Tan(geom.Radians(-20)))},
+		{"straight up", geom.HalfPi, maxPitch},
+		{"straight down", -geom.HalfPi, minPitch},
+		{"beyond up", 10, maxPitch},
+		{"beyond down", -10, minPitch},
+		{"infinite up", math.Inf(1), maxPitch},
+		{"infinite down", math.Inf(-1), minPitch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c.SetPitchAngle(tt.pitchAngle)
+			if c.pitch != tt.want {
+				t.Errorf("pitch = %v, want %v", c.pitch, tt.want)
+			}
+
+			// pitch angle matches the pitch view
+			want := math.Atan(float64(c.pitch) / (float64(c.h) * c.fovDepth))
+			if got := c.GetPitchAngle(); !geom.NearlyEqual(got, want, 1e-9) {
+				t.Errorf("pitch angle = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestPitchConvergesToClamp(t *testing.T) {
+	for _, direction := range []float64{1, -1} {
+		absolute := newTestCamera(t, 8, 8)
+		absolute.SetPitchAngle(direction * 1e6)
+
+		// pitching incrementally far past the clamp ends at the same pitch as setting it absolutely
+		incremental := newTestCamera(t, 8, 8)
+		for i := 0; i < 1000; i++ {
+			incremental.PitchCameraDegrees(direction * 5)
+		}
+
+		if incremental.pitch != absolute.pitch {
+			t.Errorf("direction %v: incremental pitch = %v, absolute pitch = %v", direction, incremental.pitch, absolute.pitch)
+		}
+		if got, want := incremental.GetPitchAngle(), absolute.GetPitchAngle(); !geom.NearlyEqual(got, want, 1e-9) {
+			t.Errorf("direction %v: incremental pitch angle = %v, absolute pitch angle = %v", direction, got, want)
+		}
+
+		// pitching back from the clamp is not held up by overshoot beyond it
+		incremental.PitchCameraDegrees(-direction * 5)
+		if incremental.pitch == absolute.pitch {
+			t.Errorf("direction %v: pitch still at clamp %v after pitching back", direction, incremental.pitch)
+		}
+	}
+
+	// narrower pitch limits clamp the angle before the pitch view
+	c := newTestCamera(t, 8, 8)
+	c.SetPitchLimits(-0.2, 0.2)
+	c.SetPitchAngle(1e6)
+	if want := int(float64(c.h) * math.Tan(0.2)); c.pitch != want {
+		t.Errorf("limited pitch = %v, want %v", c.pitch, want)
+	}
+}
+
+func TestPitchDegreesRoundTrip(t *testing.T) {
+	c := newTestCamera(t, 8, 8)
+	viewDepth := float64(c.h) * c.fovDepth
+
+	for degrees := -25.0; degrees <= 25; degrees += 5 {
+		// degrees to pixel pitch, within the pixel the view is truncated to
+		c.SetPitchDegrees(degrees)
+		exactPitch := viewDepth * math.Tan(geom.Radians(degrees))
+		if math.Abs(float64(c.pitch)-exactPitch) >= 1 {
+			t.Errorf("%v degrees: pitch = %v, want %v", degrees, c.pitch, exactPitch)
+		}
+
+		// pixel pitch back to degrees, within the angle of one pixel
+		pixelDegrees := geom.Degrees(c.GetPitchAngle())
+		pixelAngle := geom.Degrees(math.Atan(float64(c.pitch+1)/viewDepth) - math.Atan(float64(c.pitch)/viewDepth))
+		if math.Abs(pixelDegrees-degrees) > pixelAngle {
+			t.Errorf("%v degrees: pitch %v is %v degrees", degrees, c.pitch, pixelDegrees)
+		}
+
+		// and those degrees back to the same pixel pitch, which may truncate a pixel towards level
+		pitch := c.pitch
+		c.SetPitchDegrees(pixelDegrees)
+		if diff := math.Abs(float64(pitch)) - math.Abs(float64(c.pitch)); diff < 0 || diff > 1 || c.pitch*pitch < 0 {
+			t.Errorf("%v degrees: pitch after round trip = %v, want %v", degrees, c.pitch, pitch)
+		}
+
+		// pitching by the same degrees from level ends at the same pixel pitch
+		pitched := newTestCamera(t, 8, 8)
+		pitched.PitchCameraDegrees(degrees / 2)
+		pitched.PitchCameraDegrees(degrees / 2)
+		if pitched.pitch != pitch {
+			t.Errorf("%v degrees: pitched camera pitch = %v, want %v", degrees, pitched.pitch, pitch)
+		}
+
+		// and pitching back returns to level
+		pitched.PitchCameraDegrees(-degrees)
+		if pitched.pitch != 0 {
+			t.Errorf("%v degrees: pitch after pitching back = %v, want 0", degrees, pitched.pitch)
+		}
+	}
+
+	// degrees beyond the pitch clamp give the clamped pitch view
+	c.SetPitchDegrees(89)
+	if want := int(viewDepth); c.pitch != want {
+		t.Errorf("89 degrees: pitch = %v, want %v", c.pitch, want)
+	}
+	c.SetPitchDegrees(-89)
+	if want := -c.h / 2; c.pitch != want {
+		t.Errorf("-89 degrees: pitch = %v, want %v", c.pitch, want)
+	}
+}
+
+func TestNewCameraAtValidatesStartPosition(t *testing.T) {
+	grid := newTestGrid(8, 8)
+	grid[3][4] = 1
+
+	tests := []struct {
+		name    string
+		pos     geom.Vector2
+		wantErr bool
+	}{
+		{"open cell", geom.Vector2{X: 2.5, Y: 4.5}, false},
+		{"inside wall", geom.Vector2{X: 3.5, Y: 4.5}, true},
+		{"inside edge wall", geom.Vector2{X: 0.5, Y: 0.5}, true},
+		{"on wall cell boundary", geom.Vector2{X: 3, Y: 4}, true},
+		{"outside map", geom.Vector2{X: 8.5, Y: 4.5}, true},
+		{"negative position", geom.Vector2{X: -0.5, Y: 4.5}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewCameraAt(testViewWidth, testViewHeight, testTexSize, &testMap{grid: grid}, newTestTextures(), &tt.pos, 0)
+			if tt.wantErr {
+				if err == nil || c != nil {
+					t.Errorf("got camera %v and error %v, want error", c, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("NewCameraAt: %v", err)
+			}
+			if got := c.GetPosition(); *got != tt.pos {
+				t.Errorf("position = %v, want %v", *got, tt.pos)
+			}
+		})
+	}
+}
+
+func TestUpdateOutsideOfMapExplored(t *testing.T) {
+	tests := []struct {
+		name    string
+		pos     geom.Vector2
+		heading float64
+	}{
+		{"before first column", geom.Vector2{X: -2.5, Y: 4.5}, 0},
+		{"past last column", geom.Vector2{X: 9.5, Y: 4.5}, geom.Pi},
+		{"before first row", geom.Vector2{X: 2.5, Y: -2.5}, geom.HalfPi},
+		{"past last row", geom.Vector2{X: 2.5, Y: 9.5}, -geom.HalfPi},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestCamera(t, 8, 8)
+			c.SetPosition(&tt.pos)
+			c.SetHeadingAngle(tt.heading)
+			c.ResetExplored()
+			c.Update(nil)
+
+			explored := c.Explored()
+			for x := range explored {
+				for y := range explored[x] {
+					edge := x == 0 || y == 0 || x == len(explored)-1 || y == len(explored[x])-1
+					if explored[x][y] && !edge {
+						t.Errorf("cell (%v, %v) explored behind the edge walls", x, y)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestResetAndSetMapRecast(t *testing.T) {
+	c := newTestCamera(t, 8, 8)
+	start := c.GetPosition()
+	entered := 0
+	c.SetOnEnterCell(func(x, y int) { entered++ })
+
+	c.SetPosition(&geom.Vector2{X: 6.5, Y: 2.5})
+	c.SetPitchLimits(-0.1, 0.1)
+	c.SetPitchAngle(0.1)
+	c.PulseLight(100, 4)
+	c.Reset()
+
+	if got := c.GetPosition(); *got != *start {
+		t.Errorf("position %v after reset, want %v", got, start)
+	}
+	if minPitch, maxPitch := c.PitchLimits(); minPitch != -geom.HalfPi || maxPitch != geom.HalfPi {
+		t.Errorf("pitch limits %v, %v after reset, want the defaults", minPitch, maxPitch)
+	}
+	if entered != 1 {
+		t.Errorf("enter cell called %v times, want only for SetPosition", entered)
+	}
+
+	// the raycast after reset or switching map does not decay the light pulse
+	c.SetMap(&testMap{grid: newTestGrid(10, 10)})
+	if c.pulseRemaining != 4 {
+		t.Errorf("light pulse remaining %v after reset and SetMap, want 4", c.pulseRemaining)
+	}
+	if c.ColumnInfo(testViewWidth/2).MapX != 9 {
+		t.Errorf("column hit wall %v after SetMap, want the new map edge", c.ColumnInfo(testViewWidth/2).MapX)
+	}
+}
+
+func TestSkipStaticRecastSetters(t *testing.T) {
+	textures := newTestTextures()
+	textures.floor = image.NewRGBA(image.Rect(0, 0, testTexSize, testTexSize))
+	for i := range textures.floor.Pix {
+		textures.floor.Pix[i] = 255
+	}
+	c := NewCamera(testViewWidth, testViewHeight, testTexSize, &testMap{grid: newTestGrid(8, 8)}, textures)
+	c.SetSkipStaticRecast(true)
+	c.Update(nil)
+
+	// a still camera skips casting walls, floor, and ceiling
+	c.Update(nil)
+	if c.forceRecast {
+		t.Fatal("recast pending after update")
+	}
+
+	var light *Light
+	setters := []struct {
+		name string
+		set  func()
+	}{
+		{"SetCeilingTexture", func() { c.SetCeilingTexture(textures.floor) }},
+		{"SetFloorTextureScale", func() { c.SetFloorTextureScale(2) }},
+		{"SetRenderDistance", func() { c.SetRenderDistance(10) }},
+		{"SetProjection", func() { c.SetProjection(ProjectionCylindrical) }},
+		{"SetFisheyeCorrection", func() { c.SetFisheyeCorrection(0.5) }},
+		{"SetLightFalloff", func() { c.SetLightFalloff(-50) }},
+		{"SetSideShading", func() { c.SetSideShading(20) }},
+		{"SetGlobalIllumination", func() { c.SetGlobalIllumination(200) }},
+		{"SetGlobalIlluminationRGB", func() { c.SetGlobalIlluminationRGB(200, 150, 100) }},
+		{"SetLevelLighting", func() { c.SetLevelLighting(0, -50, 100) }},
+		{"ClearLevelLighting", func() { c.ClearLevelLighting(0) }},
+		{"SetLightRGB", func() { c.SetLightRGB(color.NRGBA{A: 255}, color.NRGBA{R: 200, G: 200, B: 200, A: 255}) }},
+		{"SetFog", func() { c.SetFog(color.RGBA{A: 255}, 1, 5) }},
+		{"AddLight", func() { light = c.AddLight(&geom.Vector2{X: 2, Y: 2}, color.RGBA{R: 255, A: 255}, 3) }},
+		{"RemoveLight", func() { c.RemoveLight(light) }},
+		{"ClearLights", func() { c.ClearLights() }},
+	}
+
+	for _, setter := range setters {
+		setter.set()
+		if !c.forceRecast {
+			t.Errorf("%v: no recast pending", setter.name)
+		}
+		c.Update(nil)
+		if c.forceRecast {
+			t.Errorf("%v: recast still pending after update", setter.name)
+		}
+	}
+
+	// the floor is cast again with the changed fog
+	c.SetFog(color.RGBA{A: 255}, -1, -1)
+	c.Update(nil)
+	floorX, floorY := testViewWidth/2, testViewHeight-1
+	unfogged := c.floorLvl.horBuffer.RGBAAt(floorX, floorY)
+	c.SetFog(color.RGBA{A: 255}, 0, 0.1)
+	c.Update(nil)
+	if fogged := c.floorLvl.horBuffer.RGBAAt(floorX, floorY); fogged == unfogged {
+		t.Errorf("floor pixel %v unchanged by fog", fogged)
+	}
+}
+
+const (
+	benchmarkViewWidth  = 640
+	benchmarkViewHeight = 400
+	benchmarkMapSize    = 32
+)
+
+// newBenchmarkCamera returns a camera with a floor texture at one end of a large open map, facing the other end
+func newBenchmarkCamera(b *testing.B) *Camera {
+	b.Helper()
+	textures := newTestTextures()
+	textures.floor = image.NewRGBA(image.Rect(0, 0, testTexSize, testTexSize))
+	mapObj := &testMap{grid: newTestGrid(benchmarkMapSize, benchmarkMapSize)}
+	pos := &geom.Vector2{X: 1.5, Y: benchmarkMapSize / 2}
+	c, err := NewCameraAt(benchmarkViewWidth, benchmarkViewHeight, testTexSize, mapObj, textures, pos, 0)
+	if err != nil {
+		b.Fatalf("NewCameraAt: %v", err)
+	}
+	return c
+}
+
+// newBenchmarkSprites returns sprites spread out in rows across the map from the X position to the far end
+func newBenchmarkSprites(count int, fromX float64) []Sprite {
+	texture := ebiten.NewImage(testTexSize, testTexSize)
+	rowLength := int(math.Sqrt(float64(count))) + 1
+	rowSpacing := (benchmarkMapSize - 2 - fromX) / float64(rowLength)
+	columnSpacing := (benchmarkMapSize - 2) / float64(rowLength)
+
+	sprites := make([]Sprite, count)
+	for i := range sprites {
+		x := fromX + float64(i/rowLength)*rowSpacing
+		y := 1.5 + float64(i%rowLength)*columnSpacing
+		sprites[i] = newTestSprite(x, y, texture)
+	}
+	return sprites
+}
+
+func BenchmarkUpdateSprites(b *testing.B) {
+	c := newBenchmarkCamera(b)
+	sprites := newBenchmarkSprites(500, 3)
+	c.Update(sprites)
+
+	// sort slices, sprite levels, and the floor buffer are reused between updates and not counted as allocations
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Update(sprites)
+	}
+}
+
+// goroutinePeak returns the peak number of goroutines running alongside the caller while f runs
+func goroutinePeak(f func()) int {
+	baseline := runtime.NumGoroutine()
+	done := make(chan struct{})
+	peak := make(chan int)
+	go func() {
+		max := 0
+		for {
+			select {
+			case <-done:
+				peak <- max
+				return
+			default:
+			}
+			if n := runtime.NumGoroutine(); n > max {
+				max = n
+			}
+			runtime.Gosched()
+		}
+	}()
+
+	f()
+	close(done)
+	// not counting the sampling goroutine
+	return <-peak - baseline - 1
+}
+
+func BenchmarkUpdateMaxConcurrent(b *testing.B) {
+	sprites := newBenchmarkSprites(500, 3)
+
+	for _, maxConcurrent := range []int{0, 1, 4, 16, defaultMaxConcurrent} {
+		name := fmt.Sprintf("max %v", maxConcurrent)
+		if maxConcurrent == 0 {
+			name = "serial"
+		}
+
+		b.Run(name, func(b *testing.B) {
+			c := newBenchmarkCamera(b)
+			// many more columns than the max concurrency
+			c.SetViewSize(1920, 1080)
+			if maxConcurrent == 0 {
+				c.SetConcurrency(false)
+			} else {
+				c.SetMaxConcurrent(maxConcurrent)
+			}
+			c.Update(sprites)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				c.Update(sprites)
+			}
+			b.StopTimer()
+
+			// goroutines cast at most the max concurrency of columns or sprites at a time, whatever the view width
+			peak := goroutinePeak(func() {
+				for i := 0; i < 10; i++ {
+					c.Update(sprites)
+				}
+			})
+			b.ReportMetric(float64(peak), "goroutines")
+			if peak > maxConcurrent {
+				b.Errorf("peak of %v goroutines, want at most %v", peak, maxConcurrent)
+			}
+		})
+	}
+}
+
+func BenchmarkUpdateFloor(b *testing.B) {
+	for _, floor := range []bool{false, true} {
+		for _, concurrent := range []bool{false, true} {
+			name := "walls only"
+			if floor {
+				name = "textured floor"
+			}
+			if concurrent {
+				name += "/batched"
+			} else {
+				name += "/serial"
+			}
+
+			b.Run(name, func(b *testing.B) {
+				c := newBenchmarkCamera(b)
+				if !floor {
+					c.tex.(*testTextures).floor = nil
+				}
+				c.SetConcurrency(concurrent)
+				c.Update(nil)
+
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					c.Update(nil)
+				}
+			})
+		}
+	}
+}
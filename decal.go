@@ -0,0 +1,168 @@
+package raycaster
+
+import (
+	"image"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// WallDecal is an image composited onto a wall face (e.g. bullet holes, posters)
+type WallDecal struct {
+	// X, Y are the map coordinates of the wall cell
+	X, Y int
+
+	// LevelNum is the elevation level of the wall cell
+	LevelNum int
+
+	// Side is the side of the wall the decal is on (0 for X-axis side, 1 for Y-axis side),
+	// matching the side provided to TextureAt, so it is shown from either direction along that axis
+	Side int
+
+	// U, V are the center of the decal on the wall face, from 0.0 - 1.0 across the wall texture
+	// and from the top to the bottom of the wall
+	U, V float64
+
+	// Width, Height are the size of the decal as a fraction (0.0 - 1.0) of the wall face
+	Width, Height float64
+
+	// Image is the decal image, transparent pixels show the wall behind it
+	Image *ebiten.Image
+}
+
+// wallDecalKey is the wall cell face that decals are looked up by during raycasting
+type wallDecalKey struct {
+	x, y, levelNum, side int
+}
+
+// AddWallDecal adds a decal image centered at the U, V position on the side of the wall cell,
+// returns the decal so it can be removed later. Decals added later are drawn over earlier ones.
+// Decals should not be added or removed while the camera is updating.
+func (c *Camera) AddWallDecal(x, y, levelNum, side int, u, v, width, height float64, img *ebiten.Image) *WallDecal {
+	decal := &WallDecal{X: x, Y: y, LevelNum: levelNum, Side: side, U: u, V: v, Width: width, Height: height, Image: img}
+
+	if c.wallDecals == nil {
+		c.wallDecals = make(map[wallDecalKey][]*WallDecal)
+	}
+	key := wallDecalKey{x: x, y: y, levelNum: levelNum, side: side}
+	c.wallDecals[key] = append(c.wallDecals[key], decal)
+	if levelNum >= 0 && levelNum < len(c.decalLvls) {
+		// one decal layer for each decal on the wall face, so they can all be drawn
+		c.addWallDecalLayers(c.decalLvls[levelNum], len(c.wallDecals[key]))
+	}
+	c.forceRecast = true
+	return decal
+}
+
+// RemoveWallDecal removes a wall decal that was previously added
+func (c *Camera) RemoveWallDecal(decal *WallDecal) {
+	key := wallDecalKey{x: decal.X, y: decal.Y, levelNum: decal.LevelNum, side: decal.Side}
+	decals := c.wallDecals[key]
+	for i, d := range decals {
+		if d == decal {
+			decals = append(decals[:i], decals[i+1:]...)
+			break
+		}
+	}
+
+	if len(decals) == 0 {
+		delete(c.wallDecals, key)
+	} else {
+		c.wallDecals[key] = decals
+	}
+	c.forceRecast = true
+}
+
+// ClearWallDecals removes all wall decals
+func (c *Camera) ClearWallDecals() {
+	c.wallDecals = nil
+	for i := range c.decalLvls {
+		for j := range c.decalLvls[i] {
+			c.decalLvls[i][j] = nil
+		}
+	}
+	c.forceRecast = true
+}
+
+// createDecalLevels creates the decal layers of each level for the wall behind the short walls and each short wall,
+// with a layer for each decal on the wall face of the level with the most decals
+func (c *Camera) createDecalLevels(numLevels int) [][][]*level {
+	decalLvls := make([][][]*level, numLevels)
+	for i := range decalLvls {
+		decalLvls[i] = make([][]*level, 1+maxShortWalls)
+	}
+	for key, decals := range c.wallDecals {
+		if key.levelNum >= 0 && key.levelNum < numLevels {
+			c.addWallDecalLayers(decalLvls[key.levelNum], len(decals))
+		}
+	}
+	return decalLvls
+}
+
+// addWallDecalLayers adds level slices up to the number of layers to the decal layers of each wall of a level
+func (c *Camera) addWallDecalLayers(wallDecalLayers [][]*level, numLayers int) {
+	for i := range wallDecalLayers {
+		wallDecalLayers[i] = c.addDecalLayers(wallDecalLayers[i], numLayers)
+	}
+}
+
+// addDecalLayers returns the decal layers with level slices added up to the number of layers,
+// with a source rectangle for each column since decal images are not sliced from the wall texture size
+func (c *Camera) addDecalLayers(decalLayers []*level, numLayers int) []*level {
+	for len(decalLayers) < numLayers {
+		decalLvl := c.createLevels(1)[0]
+		for x := range decalLvl.Cts {
+			decalLvl.Cts[x] = &image.Rectangle{}
+		}
+		decalLayers = append(decalLayers, decalLvl)
+	}
+	return decalLayers
+}
+
+// castDecal sets the decal slices of the column from each decal covering the wall texture column,
+// in the order the decals were added so later ones are drawn over earlier ones.
+// Decals are drawn in the decal layers over the wall slice from drawStart to drawEnd with the same lighting and fog.
+func (c *Camera) castDecal(x, mapX, mapY, levelNum, side, texX, drawStart, drawEnd int, lvl *level, decalLayers []*level) {
+	decals := c.wallDecals[wallDecalKey{x: mapX, y: mapY, levelNum: levelNum, side: side}]
+	if len(decals) == 0 || drawEnd <= drawStart {
+		return
+	}
+
+	u := (float64(texX) + 0.5) / float64(c.texSize)
+	layer := 0
+	for _, decal := range decals {
+		if decal.Image == nil || decal.Width <= 0 || decal.Height <= 0 {
+			continue
+		}
+
+		uMin, vMin := decal.U-decal.Width/2, decal.V-decal.Height/2
+		if u < uMin || u >= uMin+decal.Width {
+			continue
+		}
+
+		// clip the decal to the top and bottom of the wall face
+		vTop, vBottom := math.Max(vMin, 0), math.Min(vMin+decal.Height, 1)
+		if vBottom <= vTop {
+			continue
+		}
+
+		imgW, imgH := decal.Image.Size()
+		srcX := int((u - uMin) / decal.Width * float64(imgW))
+		srcTop := int((vTop - vMin) / decal.Height * float64(imgH))
+		srcBottom := int(math.Ceil((vBottom - vMin) / decal.Height * float64(imgH)))
+		if srcBottom <= srcTop {
+			continue
+		}
+
+		decalLvl := decalLayers[layer]
+		layer++
+
+		faceHeight := float64(drawEnd - drawStart)
+		*decalLvl.Cts[x] = image.Rect(srcX, srcTop, srcX+1, srcBottom)
+		decalLvl.Sv[x].Min.Y = drawStart + int(vTop*faceHeight)
+		decalLvl.Sv[x].Max.Y = drawStart + int(vBottom*faceHeight)
+		*decalLvl.St[x] = *lvl.St[x]
+		decalLvl.Sf[x] = lvl.Sf[x]
+		decalLvl.CurrTex[x] = decal.Image
+	}
+}
@@ -0,0 +1,67 @@
+package raycaster
+
+import (
+	"testing"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/harbdog/raycaster-go/geom"
+)
+
+func TestCastOverlappingWallDecals(t *testing.T) {
+	c := newTestCamera(t, 8, 8)
+	c.SetPosition(&geom.Vector2{X: 4.5, Y: 4.5})
+
+	// the center column looks at the middle of the wall face at X 7
+	poster := ebiten.NewImage(testTexSize, testTexSize)
+	hole := ebiten.NewImage(4, 4)
+	aside := ebiten.NewImage(4, 4)
+	scorch := ebiten.NewImage(8, 8)
+	posterDecal := c.AddWallDecal(7, 4, 0, 0, 0.5, 0.5, 0.8, 0.8, poster)
+	c.AddWallDecal(7, 4, 0, 0, 0.5, 0.4, 0.5, 0.2, hole)
+	c.AddWallDecal(7, 4, 0, 0, 0.1, 0.5, 0.1, 0.1, aside)
+	c.AddWallDecal(7, 4, 0, 0, 0.5, 0.6, 0.5, 0.5, scorch)
+
+	centerX := testViewWidth / 2
+	castDecals := func() []*ebiten.Image {
+		c.Update(nil)
+		var images []*ebiten.Image
+		for _, decalLvl := range c.decalLvls[0][0] {
+			if decalLvl.CurrTex[centerX] != nil {
+				images = append(images, decalLvl.CurrTex[centerX])
+			}
+		}
+		return images
+	}
+
+	// every decal covering the column is cast, in the order they were added
+	want := []*ebiten.Image{poster, hole, scorch}
+	if got := castDecals(); !equalImages(got, want) {
+		t.Errorf("cast decals %v, want %v", got, want)
+	}
+	if len(c.decalLvls[0][0]) != 4 {
+		t.Errorf("%v decal layers, want one for each decal on the wall face", len(c.decalLvls[0][0]))
+	}
+
+	c.RemoveWallDecal(posterDecal)
+	want = []*ebiten.Image{hole, scorch}
+	if got := castDecals(); !equalImages(got, want) {
+		t.Errorf("cast decals after removal %v, want %v", got, want)
+	}
+
+	c.ClearWallDecals()
+	if got := castDecals(); len(got) != 0 {
+		t.Errorf("cast decals after clearing %v, want none", got)
+	}
+}
+
+func equalImages(a, b []*ebiten.Image) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
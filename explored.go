@@ -0,0 +1,43 @@
+package raycaster
+
+import "sync/atomic"
+
+// markExplored flags the cell of the first level as explored, ignoring cells outside of the map
+func (c *Camera) markExplored(mapX, mapY int) {
+	if mapX < 0 || mapY < 0 || mapX >= c.mapWidth || mapY >= c.mapHeight {
+		return
+	}
+	i := mapX*c.mapHeight + mapY
+	if atomic.LoadUint32(&c.explored[i]) == 0 {
+		atomic.StoreUint32(&c.explored[i], 1)
+	}
+}
+
+// IsExplored returns true if the cell of the first level at the given x, y map coordinates
+// has been reached by a ray since the camera was created or last reset with ResetExplored
+func (c *Camera) IsExplored(x, y int) bool {
+	if x < 0 || y < 0 || x >= c.mapWidth || y >= c.mapHeight {
+		return false
+	}
+	return atomic.LoadUint32(&c.explored[x*c.mapHeight+y]) != 0
+}
+
+// Explored returns a copy of the explored state of each cell of the first level,
+// indexed the same as the level grid (e.g. for minimaps that only reveal explored areas)
+func (c *Camera) Explored() [][]bool {
+	explored := make([][]bool, c.mapWidth)
+	for x := 0; x < c.mapWidth; x++ {
+		explored[x] = make([]bool, c.mapHeight)
+		for y := 0; y < c.mapHeight; y++ {
+			explored[x][y] = atomic.LoadUint32(&c.explored[x*c.mapHeight+y]) != 0
+		}
+	}
+	return explored
+}
+
+// ResetExplored clears the explored state of all cells (e.g. when starting a new level)
+func (c *Camera) ResetExplored() {
+	for i := range c.explored {
+		atomic.StoreUint32(&c.explored[i], 0)
+	}
+}
@@ -28,6 +28,13 @@ func MaxInt(x, y int) int {
 	return x
 }
 
+func MinInt(x, y int) int {
+	if x > y {
+		return y
+	}
+	return x
+}
+
 // Clamp - converted C# method MathHelper.ClampInt
 // Restricts a value to be within a specified range.
 func Clamp(value float64, min float64, max float64) float64 {
@@ -71,6 +78,44 @@ func (v *Vector2) Copy() *Vector2 {
 	return &Vector2{X: v.X, Y: v.Y}
 }
 
+// Scale multiplies both components by the factor
+func (v *Vector2) Scale(factor float64) *Vector2 {
+	v.X *= factor
+	v.Y *= factor
+	return v
+}
+
+// Length returns the magnitude of the vector
+func (v *Vector2) Length() float64 {
+	return math.Sqrt(sq(v.X) + sq(v.Y))
+}
+
+// Normalize scales the vector to unit length (a zero length vector is left unchanged)
+func (v *Vector2) Normalize() *Vector2 {
+	length := v.Length()
+	if length == 0 {
+		return v
+	}
+	return v.Scale(1 / length)
+}
+
+// Distance returns the distance between the two points
+func (v *Vector2) Distance(v2 *Vector2) float64 {
+	return Distance(v.X, v.Y, v2.X, v2.Y)
+}
+
+// DistanceSquared returns the d^2 of the distance between the two points
+func (v *Vector2) DistanceSquared(v2 *Vector2) float64 {
+	return Distance2(v.X, v.Y, v2.X, v2.Y)
+}
+
+// Lerp linearly interpolates towards v2 by t (0.0 remains at v, 1.0 moves to v2)
+func (v *Vector2) Lerp(v2 *Vector2, t float64) *Vector2 {
+	v.X += (v2.X - v.X) * t
+	v.Y += (v2.Y - v.Y) * t
+	return v
+}
+
 func (v *Vector2) Equals(v2 *Vector2) bool {
 	return v.X == v2.X && v.Y == v2.Y
 }
@@ -167,6 +212,19 @@ func LineIntersection(l1, l2 Line) (float64, float64, bool) {
 	return x, y, true
 }
 
+// SegmentIntersection calculates the intersection point of the line segment from a1 to a2
+// and the line segment from b1 to b2, returns false if the segments do not cross (including parallel segments).
+func SegmentIntersection(a1, a2, b1, b2 *Vector2) (*Vector2, bool) {
+	x, y, ok := LineIntersection(
+		Line{X1: a1.X, Y1: a1.Y, X2: a2.X, Y2: a2.Y},
+		Line{X1: b1.X, Y1: b1.Y, X2: b2.X, Y2: b2.Y},
+	)
+	if !ok {
+		return nil, false
+	}
+	return &Vector2{X: x, Y: y}, true
+}
+
 type Circle struct {
 	X, Y   float64
 	Radius float64
@@ -0,0 +1,134 @@
+package geom
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSegmentIntersection(t *testing.T) {
+	tests := []struct {
+		name           string
+		a1, a2, b1, b2 Vector2
+		want           *Vector2
+	}{
+		{"crossing", Vector2{0, 0}, Vector2{2, 2}, Vector2{0, 2}, Vector2{2, 0}, &Vector2{1, 1}},
+		{"crossing axis-aligned", Vector2{0, 1}, Vector2{4, 1}, Vector2{3, 0}, Vector2{3, 2}, &Vector2{3, 1}},
+		{"touching at endpoint", Vector2{0, 0}, Vector2{1, 1}, Vector2{1, 1}, Vector2{2, 0}, &Vector2{1, 1}},
+		{"lines cross beyond segments", Vector2{0, 0}, Vector2{1, 1}, Vector2{3, 0}, Vector2{2, 1}, nil},
+		{"parallel", Vector2{0, 0}, Vector2{2, 0}, Vector2{0, 1}, Vector2{2, 1}, nil},
+		{"collinear overlapping", Vector2{0, 0}, Vector2{2, 0}, Vector2{1, 0}, Vector2{3, 0}, nil},
+		{"collinear disjoint", Vector2{0, 0}, Vector2{1, 1}, Vector2{2, 2}, Vector2{3, 3}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := SegmentIntersection(&tt.a1, &tt.a2, &tt.b1, &tt.b2)
+			if tt.want == nil {
+				if ok {
+					t.Errorf("got intersection %v, want none", got)
+				}
+				return
+			}
+
+			if !ok {
+				t.Fatalf("got no intersection, want %v", tt.want)
+			}
+			if !got.NearlyEquals(tt.want, 1e-9) {
+				t.Errorf("got intersection %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		name   string
+		v, v2  Vector2
+		want   float64
+		wantSq float64
+	}{
+		{"same point", Vector2{1.5, -2}, Vector2{1.5, -2}, 0, 0},
+		{"along X", Vector2{1, 2}, Vector2{4, 2}, 3, 9},
+		{"along Y", Vector2{1, 2}, Vector2{1, -3}, 5, 25},
+		{"diagonal", Vector2{-1, -1}, Vector2{2, 3}, 5, 25},
+		{"fractional", Vector2{0, 0}, Vector2{0.5, 0.5}, math.Sqrt(0.5), 0.5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.v.Distance(&tt.v2); !NearlyEqual(got, tt.want, 1e-12) {
+				t.Errorf("Distance = %v, want %v", got, tt.want)
+			}
+			if got := tt.v2.Distance(&tt.v); !NearlyEqual(got, tt.want, 1e-12) {
+				t.Errorf("reverse Distance = %v, want %v", got, tt.want)
+			}
+			if got := tt.v.DistanceSquared(&tt.v2); !NearlyEqual(got, tt.wantSq, 1e-12) {
+				t.Errorf("DistanceSquared = %v, want %v", got, tt.wantSq)
+			}
+		})
+	}
+}
+
+func TestLerp(t *testing.T) {
+	tests := []struct {
+		name  string
+		v, v2 Vector2
+		t     float64
+		want  Vector2
+	}{
+		{"start", Vector2{1, 2}, Vector2{5, -2}, 0, Vector2{1, 2}},
+		{"end", Vector2{1, 2}, Vector2{5, -2}, 1, Vector2{5, -2}},
+		{"halfway", Vector2{1, 2}, Vector2{5, -2}, 0.5, Vector2{3, 0}},
+		{"quarter", Vector2{0, 0}, Vector2{-4, 8}, 0.25, Vector2{-1, 2}},
+		{"beyond end", Vector2{0, 0}, Vector2{2, 1}, 2, Vector2{4, 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := tt.v
+			got := v.Lerp(&tt.v2, tt.t)
+			if !got.NearlyEquals(&tt.want, 1e-12) {
+				t.Errorf("Lerp = %v, want %v", got, tt.want)
+			}
+			// interpolated in place
+			if got != &v {
+				t.Errorf("Lerp returned a new vector instead of the receiver")
+			}
+		})
+	}
+}
+
+func TestLength(t *testing.T) {
+	tests := []struct {
+		v    Vector2
+		want float64
+	}{
+		{Vector2{0, 0}, 0},
+		{Vector2{3, 4}, 5},
+		{Vector2{-3, 4}, 5},
+		{Vector2{0, -2}, 2},
+		{Vector2{1, 1}, math.Sqrt2},
+	}
+
+	for _, tt := range tests {
+		if got := tt.v.Length(); !NearlyEqual(got, tt.want, 1e-12) {
+			t.Errorf("%v.Length() = %v, want %v", tt.v, got, tt.want)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		v, want Vector2
+	}{
+		{Vector2{3, 4}, Vector2{0.6, 0.8}},
+		{Vector2{0, -2}, Vector2{0, -1}},
+		{Vector2{0, 0}, Vector2{0, 0}},
+	}
+
+	for _, tt := range tests {
+		if got := tt.v.Copy().Normalize(); !got.NearlyEquals(&tt.want, 1e-12) {
+			t.Errorf("%v.Normalize() = %v, want %v", tt.v, got, tt.want)
+		}
+	}
+}
@@ -18,6 +18,9 @@ type level struct {
 	// St --current slice tint (for lighting/shading)--//
 	St []*color.RGBA
 
+	// Sf --current slice fog amount (0 for no fog, 1 for fully obscured)--//
+	Sf []float64
+
 	// CurrTex --the texture to use as source
 	CurrTex []*ebiten.Image
 }
@@ -34,12 +37,34 @@ func sliceView(width, height int) []*image.Rectangle {
 	return arr
 }
 
+// makeTints creates slice tints for each x in width.
+func makeTints(width int) []*color.RGBA {
+	arr := make([]*color.RGBA, width)
+
+	for x := 0; x < width; x++ {
+		arr[x] = &color.RGBA{255, 255, 255, 255}
+	}
+
+	return arr
+}
+
 // horLevel is for handling horizontal renders that cannot use vertical slices (e.g. floor, ceiling)
 type horLevel struct {
 	// horBuffer is the image representing the pixels to render during the update
 	horBuffer *image.RGBA
+
+	// image is the texture the horBuffer pixels are copied to during the draw
+	image *ebiten.Image
 }
 
 func (h *horLevel) initialize(width, height int) {
 	h.horBuffer = image.NewRGBA(image.Rect(0, 0, width, height))
+	h.image = ebiten.NewImage(width, height)
+}
+
+// clear sets all pixels of the horBuffer to transparent so areas not raycasted show what is behind them
+func (h *horLevel) clear() {
+	for i := range h.horBuffer.Pix {
+		h.horBuffer.Pix[i] = 0
+	}
 }
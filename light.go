@@ -0,0 +1,87 @@
+package raycaster
+
+import (
+	"image/color"
+
+	"github.com/harbdog/raycaster-go/geom"
+)
+
+// lightRGB is an amount of light added to each color channel
+type lightRGB struct {
+	R, G, B float64
+}
+
+// levelLighting overrides the camera light falloff and global illumination for a specific level
+type levelLighting struct {
+	falloff      float64
+	illumination lightRGB
+}
+
+// Light is a point light positioned on the map that illuminates nearby walls, floors, and sprites
+type Light struct {
+	// Pos is the X,Y map position of the light, can be updated each frame for moving lights
+	Pos *geom.Vector2
+
+	// Color is the color of the light at its position
+	Color color.RGBA
+
+	// Radius is the distance from the light position where its illumination fades out completely
+	Radius float64
+}
+
+// illuminationAt returns the light added to each color channel at the given map position,
+// falling off linearly with distance from the light position
+func (l *Light) illuminationAt(x, y float64) lightRGB {
+	if l.Pos == nil || l.Radius <= 0 {
+		return lightRGB{}
+	}
+
+	distance := geom.Distance(l.Pos.X, l.Pos.Y, x, y)
+	if distance >= l.Radius {
+		return lightRGB{}
+	}
+
+	intensity := 1 - distance/l.Radius
+	return lightRGB{
+		R: float64(l.Color.R) * intensity,
+		G: float64(l.Color.G) * intensity,
+		B: float64(l.Color.B) * intensity,
+	}
+}
+
+// AddLight adds a point light at the map position, returns the light so its position, color,
+// or radius can be updated for moving or flickering lights (call ForceRecast after updating it when skipping static recasts)
+func (c *Camera) AddLight(pos *geom.Vector2, lightColor color.RGBA, radius float64) *Light {
+	light := &Light{Pos: pos, Color: lightColor, Radius: radius}
+	c.lights = append(c.lights, light)
+	c.forceRecast = true
+	return light
+}
+
+// RemoveLight removes a point light that was previously added
+func (c *Camera) RemoveLight(light *Light) {
+	for i, l := range c.lights {
+		if l == light {
+			c.lights = append(c.lights[:i], c.lights[i+1:]...)
+			c.forceRecast = true
+			return
+		}
+	}
+}
+
+// PulseLight adds a transient brightening of all walls, floors, and sprites (e.g. muzzle flashes)
+// starting at the intensity and decaying linearly over the number of updates, replacing any current pulse
+func (c *Camera) PulseLight(intensity float64, durationFrames int) {
+	if durationFrames < 1 {
+		durationFrames = 1
+	}
+	c.pulseIntensity = intensity
+	c.pulseDuration = durationFrames
+	c.pulseRemaining = durationFrames
+}
+
+// ClearLights removes all point lights
+func (c *Camera) ClearLights() {
+	c.lights = nil
+	c.forceRecast = true
+}
@@ -1,5 +1,11 @@
 package raycaster
 
+import (
+	"image/color"
+
+	"github.com/harbdog/raycaster-go/geom"
+)
+
 type Map interface {
 	// Level returns the 2-dimensional array of texture indices for each level
 	Level(levelNum int) [][]int
@@ -7,3 +13,40 @@ type Map interface {
 	// NumLevels returns the number of vertical levels (minimum of 1)
 	NumLevels() int
 }
+
+// WallHeightMap is an optional interface a Map can implement to render walls of varying heights
+type WallHeightMap interface {
+	// WallHeight returns the height of the wall at the given x, y map coordinates and level number,
+	// relative to the height of a level (1.0 for a regular full height wall).
+	// Short walls (below 1.0) are seen over, what is behind them is drawn above their top.
+	WallHeight(x, y, levelNum int) float64
+}
+
+// OffsetWallMap is an optional interface a Map can implement to render thin walls inset within their cell (e.g. doors)
+type OffsetWallMap interface {
+	// WallOffset returns the offset (0.0 - 1.0) of the thin wall from the cell edge along the X-axis (side 0)
+	// or Y-axis (side 1) at the given x, y map coordinates and level number, or an offset of 0 for a regular wall
+	WallOffset(x, y, levelNum int) (offset float64, side int)
+}
+
+// GlassWallMap is an optional interface a Map can implement to render walls as see-through colored glass.
+// The nearest 4 glass walls in each column are drawn, farther glass walls are seen through without being drawn.
+type GlassWallMap interface {
+	// WallGlass returns whether the wall at the given x, y map coordinates and level number is glass,
+	// and the tint color of the glass where the alpha is its opacity
+	WallGlass(x, y, levelNum int) (tint color.RGBA, isGlass bool)
+}
+
+// DoorMap is an optional interface a Map can implement to slide open thin walls from OffsetWallMap as doors
+type DoorMap interface {
+	// DoorOpenness returns how far open (0.0 - 1.0) the door is at the given x, y map coordinates and level number,
+	// where 0.0 is fully closed and 1.0 is fully open
+	DoorOpenness(x, y, levelNum int) float64
+}
+
+// SpawnMap is an optional interface a Map can implement to provide the camera start position and heading,
+// keeping spawn data with the map it belongs to
+type SpawnMap interface {
+	// SpawnPose returns the X,Y map position and heading angle (in radians) the camera starts at
+	SpawnPose() (pos *geom.Vector2, headingAngle float64)
+}
@@ -0,0 +1,105 @@
+package raycaster
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// MinimapColors contains the colors used to render the minimap
+type MinimapColors struct {
+	// Wall is the color of map cells containing a wall
+	Wall color.RGBA
+
+	// Floor is the color of open map cells
+	Floor color.RGBA
+
+	// Camera is the color of the camera position and its facing cone
+	Camera color.RGBA
+
+	// Sprite is the color of sprite positions
+	Sprite color.RGBA
+}
+
+// DefaultMinimapColors returns the default colors used to render the minimap
+func DefaultMinimapColors() MinimapColors {
+	return MinimapColors{
+		Wall:   color.RGBA{R: 128, G: 128, B: 128, A: 255},
+		Floor:  color.RGBA{R: 32, G: 32, B: 32, A: 192},
+		Camera: color.RGBA{R: 255, G: 255, B: 0, A: 255},
+		Sprite: color.RGBA{R: 255, G: 0, B: 0, A: 255},
+	}
+}
+
+// RenderMinimap renders an overhead view of the first level of the map, the camera position and facing cone,
+// and the sprites from the last update. Each map cell is rendered as scale x scale pixels.
+func (c *Camera) RenderMinimap(scale int, colors MinimapColors) *ebiten.Image {
+	if scale < 1 {
+		scale = 1
+	}
+
+	minimap := image.NewRGBA(image.Rect(0, 0, c.mapWidth*scale, c.mapHeight*scale))
+
+	// map cells
+	firstLevel := c.mapObj.Level(0)
+	for x := 0; x < c.mapWidth; x++ {
+		for y := 0; y < c.mapHeight; y++ {
+			cellColor := colors.Floor
+			if firstLevel[x][y] > 0 {
+				cellColor = colors.Wall
+			}
+
+			for px := x * scale; px < (x+1)*scale; px++ {
+				for py := y * scale; py < (y+1)*scale; py++ {
+					minimap.SetRGBA(px, py, cellColor)
+				}
+			}
+		}
+	}
+
+	// sprites
+	for _, sprite := range c.sprites {
+		spritePos := sprite.Pos()
+		drawMinimapDot(minimap, spritePos.X*float64(scale), spritePos.Y*float64(scale), scale/4, colors.Sprite)
+	}
+
+	// camera facing cone from the dir and plane vectors
+	pos, dir, plane := c.GetPosition(), c.GetDirection(), c.GetPlane()
+	camX, camY := pos.X*float64(scale), pos.Y*float64(scale)
+	coneLength := 2 * float64(scale)
+	for _, planeSign := range []float64{-1, 1} {
+		coneX := dir.X + planeSign*plane.X
+		coneY := dir.Y + planeSign*plane.Y
+		coneNorm := math.Sqrt(coneX*coneX + coneY*coneY)
+		drawMinimapLine(minimap, camX, camY, camX+coneX/coneNorm*coneLength, camY+coneY/coneNorm*coneLength, colors.Camera)
+	}
+	drawMinimapDot(minimap, camX, camY, scale/4, colors.Camera)
+
+	return ebiten.NewImageFromImage(minimap)
+}
+
+// drawMinimapDot draws a filled square of the given radius centered at the pixel position
+func drawMinimapDot(minimap *image.RGBA, x, y float64, radius int, dotColor color.RGBA) {
+	cx, cy := int(x), int(y)
+	for px := cx - radius; px <= cx+radius; px++ {
+		for py := cy - radius; py <= cy+radius; py++ {
+			minimap.SetRGBA(px, py, dotColor)
+		}
+	}
+}
+
+// drawMinimapLine draws a line between the two pixel positions
+func drawMinimapLine(minimap *image.RGBA, x1, y1, x2, y2 float64, lineColor color.RGBA) {
+	steps := int(math.Max(math.Abs(x2-x1), math.Abs(y2-y1)))
+	if steps < 1 {
+		minimap.SetRGBA(int(x1), int(y1), lineColor)
+		return
+	}
+
+	for i := 0; i <= steps; i++ {
+		t := float64(i) / float64(steps)
+		minimap.SetRGBA(int(x1+t*(x2-x1)), int(y1+t*(y2-y1)), lineColor)
+	}
+}
@@ -0,0 +1,58 @@
+package raycaster
+
+import (
+	"image"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// Overlay is an image fixed to the screen that is drawn over the raycasted view (e.g. weapon or HUD)
+type Overlay struct {
+	// Image is the overlay image to draw, nil to skip drawing it
+	Image *ebiten.Image
+
+	// Pos is the screen position of the top left corner of the overlay,
+	// can be updated each frame for effects such as weapon bobbing
+	Pos image.Point
+
+	// Scale is the scale factor of the overlay image (for no scaling, 1.0)
+	Scale float64
+}
+
+// AddOverlay adds an image drawn at the screen position after walls, floors, and sprites,
+// returns the overlay so its image, position, or scale can be updated
+func (c *Camera) AddOverlay(img *ebiten.Image, pos image.Point) *Overlay {
+	overlay := &Overlay{Image: img, Pos: pos, Scale: 1.0}
+	c.overlays = append(c.overlays, overlay)
+	return overlay
+}
+
+// RemoveOverlay removes an overlay that was previously added
+func (c *Camera) RemoveOverlay(overlay *Overlay) {
+	for i, o := range c.overlays {
+		if o == overlay {
+			c.overlays = append(c.overlays[:i], c.overlays[i+1:]...)
+			return
+		}
+	}
+}
+
+// ClearOverlays removes all overlays
+func (c *Camera) ClearOverlays() {
+	c.overlays = nil
+}
+
+// drawOverlays draws the overlays in the order they were added, unaffected by the render scale
+func (c *Camera) drawOverlays(screen *ebiten.Image) {
+	for _, overlay := range c.overlays {
+		if overlay.Image == nil {
+			continue
+		}
+
+		op := &ebiten.DrawImageOptions{}
+		op.Filter = ebiten.FilterNearest
+		op.GeoM.Scale(overlay.Scale, overlay.Scale)
+		op.GeoM.Translate(float64(overlay.Pos.X), float64(overlay.Pos.Y))
+		screen.DrawImage(overlay.Image, op)
+	}
+}
@@ -0,0 +1,481 @@
+package raycaster
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/harbdog/raycaster-go/geom"
+)
+
+// RayHit contains the results of a ray cast from the camera to the first wall hit
+type RayHit struct {
+	// MapX, MapY are the map coordinates of the wall that was hit
+	MapX, MapY int
+
+	// Side is the side of the wall that was hit (0 for X-axis side, 1 for Y-axis side)
+	Side int
+
+	// Distance is the perpendicular distance from the camera to where the wall was hit
+	Distance float64
+
+	// WallX is where exactly along the wall (0.0 - 1.0) it was hit
+	WallX float64
+
+	// TextureX is the x coordinate on the wall texture where it was hit
+	TextureX int
+}
+
+// ColumnInfo contains the wall raycast results of a single screen column from the last update
+type ColumnInfo struct {
+	// MapX, MapY are the map coordinates of the wall that was hit
+	MapX, MapY int
+
+	// Side is the side of the wall that was hit (0 for X-axis side, 1 for Y-axis side)
+	Side int
+
+	// Distance is the perpendicular distance from the camera to where the wall was hit
+	Distance float64
+
+	// DrawStart, DrawEnd are the screen rows where the wall slice starts and ends (may be outside the screen)
+	DrawStart, DrawEnd int
+
+	// Texture is the wall texture drawn in the column (nil if no wall was drawn)
+	Texture *ebiten.Image
+}
+
+// ColumnInfo returns the wall raycast results of the first level at the given screen column
+// during the last update, without casting a new ray (nil if the column is outside of the camera view)
+func (c *Camera) ColumnInfo(screenX int) *ColumnInfo {
+	if screenX < 0 || screenX >= c.viewW {
+		return nil
+	}
+
+	info := c.columns[c.toViewX(screenX)]
+	// draw rows are converted from raycasted resolution to screen resolution
+	info.DrawStart = c.toScreenY(info.DrawStart)
+	info.DrawEnd = c.toScreenY(info.DrawEnd)
+	return &info
+}
+
+// RayCast casts a ray from the camera pose of the last update through the given screen column on the first level,
+// returns the first wall hit (passing through glass walls) or nil if no wall was hit within the render distance
+func (c *Camera) RayCast(screenX int) *RayHit {
+	if screenX < 0 || screenX >= c.viewW {
+		return nil
+	}
+
+	rayDirX, rayDirY := c.getRayDir(c.toViewX(screenX))
+	mapX, mapY, side, hit, perpWallDist, wallX := c.castRay(c.pose.pos.X, c.pose.pos.Y, rayDirX, rayDirY, c.mapObj.Level(0), 0, nil, nil, false)
+	if hit != 1 {
+		return nil
+	}
+	return &RayHit{
+		MapX:     mapX,
+		MapY:     mapY,
+		Side:     side,
+		Distance: perpWallDist,
+		WallX:    wallX,
+		TextureX: c.getWallTexX(wallX, side, rayDirX, rayDirY),
+	}
+}
+
+// Projection is the mapping of screen columns to ray directions
+type Projection int
+
+const (
+	// ProjectionPlanar casts rays through evenly spaced points on the flat camera plane (default)
+	ProjectionPlanar Projection = iota
+	// ProjectionCylindrical casts rays at evenly spaced angles, reducing stretching at the screen edges for wide FOV
+	ProjectionCylindrical
+)
+
+// getRayDir returns the direction of the ray cast through the given screen column
+func (c *Camera) getRayDir(x int) (float64, float64) {
+	cameraX := 2.0*float64(x)/float64(c.w) - 1.0 //x-coordinate in camera space
+	if c.projection == ProjectionCylindrical {
+		// evenly spaced angles within the FOV, mapped back to the camera plane
+		halfFov := c.fovAngle / 2
+		cameraX = math.Tan(cameraX*halfFov) / math.Tan(halfFov)
+	}
+	rayDirX := c.pose.dir.X + c.pose.plane.X*cameraX
+	rayDirY := c.pose.dir.Y + c.pose.plane.Y*cameraX
+	return rayDirX, rayDirY
+}
+
+// getScreenX returns the screen column of a point at the x-coordinate on the camera plane (-1.0 to 1.0 in view)
+func (c *Camera) getScreenX(cameraX float64) float64 {
+	if c.projection == ProjectionCylindrical {
+		// inverse of the ray direction mapping in getRayDir
+		halfFov := c.fovAngle / 2
+		cameraX = math.Atan(cameraX*math.Tan(halfFov)) / halfFov
+	}
+	return float64(c.w) / 2 * (1 + cameraX)
+}
+
+// maxGlassLayers is the number of glass walls drawn in each column, farther glass walls are seen through
+// without being drawn
+const maxGlassLayers = 4
+
+// glassHit is a glass wall a ray passed through before reaching an opaque wall
+type glassHit struct {
+	mapX, mapY   int
+	side         int
+	perpWallDist float64
+	wallX        float64
+	tint         color.RGBA
+}
+
+// glassHits are the glass walls a ray passed through before reaching an opaque wall, nearest first
+type glassHits struct {
+	count int
+	hits  [maxGlassLayers]glassHit
+}
+
+// maxShortWalls is the number of walls shorter than a level seen over in each column,
+// a ray stops at the next short wall as if it were full height
+const maxShortWalls = 4
+
+// shortWallHit is a wall shorter than a level that a ray saw over before reaching the wall behind it
+type shortWallHit struct {
+	mapX, mapY   int
+	side         int
+	perpWallDist float64
+	wallX        float64
+
+	// screen row of the top of the wall slice, set once it is cast
+	drawStart int
+}
+
+// shortWallHits are the walls shorter than a level that a ray saw over, nearest first
+type shortWallHits struct {
+	count int
+	hits  [maxShortWalls]shortWallHit
+}
+
+// clipY returns the screen row a slice at the perpendicular distance is clipped to end at in the column,
+// the top of the nearest short walls in front of it (endY if there are none)
+func (s *shortWallHits) clipY(perpDist float64, endY int) int {
+	for i := 0; i < s.count && s.hits[i].perpWallDist < perpDist; i++ {
+		endY = geom.MinInt(endY, s.hits[i].drawStart)
+	}
+	return endY
+}
+
+// castRay performs DDA from the ray position in the ray direction until it hits a wall on the level grid (hit = 1),
+// or hits the render distance or grid boundary (hit = 2).
+// Glass walls are passed through, the nearest ones are recorded in glass if not nil.
+// Walls shorter than a level are seen over when shortWalls is not nil, the nearest ones are recorded in it.
+// When explore is true, each cell within render distance that the ray reaches is marked as explored.
+// Returns the map coordinates and side of the last cell the ray reached, the perpendicular distance to it,
+// and where exactly along the wall it was hit.
+func (c *Camera) castRay(rayPosX, rayPosY, rayDirX, rayDirY float64, grid [][]int, levelNum int, glass *glassHits, shortWalls *shortWallHits, explore bool) (mapX, mapY, side, hit int, perpWallDist, wallX float64) {
+	offsetMap, _ := c.mapObj.(OffsetWallMap)
+	doorMap, _ := c.mapObj.(DoorMap)
+	glassMap, _ := c.mapObj.(GlassWallMap)
+	heightMap, _ := c.mapObj.(WallHeightMap)
+
+	//which box of the map we're in
+	mapX = int(rayPosX)
+	mapY = int(rayPosY)
+
+	//length of ray from current position to next x or y-side
+	var sideDistX float64
+	var sideDistY float64
+
+	//length of ray from one x or y-side to next x or y-side
+	deltaDistX := math.Abs(1 / rayDirX)
+	deltaDistY := math.Abs(1 / rayDirY)
+
+	//what direction to step in x or y-direction (either +1 or -1)
+	var stepX int
+	var stepY int
+
+	hit = 0   //was there a wall hit?
+	side = -1 //was a NS or a EW wall hit?
+
+	var doorOffset float64 //how far the door that was hit has slid open
+
+	//calculate step and initial sideDist
+	if rayDirX < 0 {
+		stepX = -1
+		sideDistX = (rayPosX - float64(mapX)) * deltaDistX
+	} else {
+		stepX = 1
+		sideDistX = (float64(mapX) + 1.0 - rayPosX) * deltaDistX
+	}
+
+	if rayDirY < 0 {
+		stepY = -1
+		sideDistY = (rayPosY - float64(mapY)) * deltaDistY
+	} else {
+		stepY = 1
+		sideDistY = (float64(mapY) + 1.0 - rayPosY) * deltaDistY
+	}
+
+	//a ray parallel to an axis never reaches the next side along the other axis
+	//(avoids NaN from 0 * Inf when the ray starts exactly on a grid line)
+	if rayDirX == 0 {
+		sideDistX = math.Inf(1)
+	}
+	if rayDirY == 0 {
+		sideDistY = math.Inf(1)
+	}
+
+	if explore {
+		// the cell the ray starts from is always seen
+		c.markExplored(mapX, mapY)
+	}
+
+	//perform DDA
+	for hit == 0 {
+		//jump to next map square, OR in x-direction, OR in y-direction
+		if sideDistX < sideDistY {
+			sideDistX += deltaDistX
+			mapX += stepX
+			side = 0
+		} else {
+			sideDistY += deltaDistY
+			mapY += stepY
+			side = 1
+		}
+
+		//Calculate distance of perpendicular ray (oblique distance will give fisheye effect!)
+		if side == 0 {
+			perpWallDist = sideDistX - deltaDistX
+		} else {
+			perpWallDist = sideDistY - deltaDistY
+		}
+
+		//Check if ray has hit a wall
+		if mapX >= 0 && mapY >= 0 && mapX < c.mapWidth && mapY < c.mapHeight {
+			if perpWallDist > c.renderDistance {
+				// hit render distance bounds
+				hit = 2
+			}
+
+			if explore && hit == 0 {
+				c.markExplored(mapX, mapY)
+			}
+
+			if hit == 0 && grid[mapX][mapY] > 0 {
+				// only render walls within render distance
+				hit = 1
+
+				if offsetMap != nil {
+					if offset, offsetSide := offsetMap.WallOffset(mapX, mapY, levelNum); offset > 0 {
+						// thin wall inset within the cell, only hit if the ray reaches it before leaving the cell
+						offsetDist, ok := getOffsetWallDist(rayPosX, rayPosY, rayDirX, rayDirY, mapX, mapY, offset, offsetSide)
+						if ok && offsetDist >= perpWallDist && offsetDist < math.Min(sideDistX, sideDistY) {
+							perpWallDist = offsetDist
+							side = offsetSide
+						} else {
+							hit = 0
+						}
+
+						if hit == 1 && doorMap != nil {
+							// door slides open along the wall, the ray passes through the open part
+							openness := doorMap.DoorOpenness(mapX, mapY, levelNum)
+							doorX := getWallX(rayPosX, rayPosY, rayDirX, rayDirY, side, perpWallDist)
+							if doorX < openness {
+								hit = 0
+							} else {
+								doorOffset = openness
+							}
+						}
+					}
+				}
+
+				if hit == 1 && glassMap != nil {
+					if tint, isGlass := glassMap.WallGlass(mapX, mapY, levelNum); isGlass {
+						// glass wall is seen through, the nearest ones are drawn over what is behind them
+						if glass != nil && glass.count < maxGlassLayers {
+							glass.hits[glass.count] = glassHit{
+								mapX:         mapX,
+								mapY:         mapY,
+								side:         side,
+								perpWallDist: perpWallDist,
+								wallX:        getWallX(rayPosX, rayPosY, rayDirX, rayDirY, side, perpWallDist) - doorOffset,
+								tint:         tint,
+							}
+							glass.count++
+						}
+						hit = 0
+						doorOffset = 0
+					}
+				}
+
+				if hit == 1 && heightMap != nil && shortWalls != nil && shortWalls.count < maxShortWalls {
+					if heightMap.WallHeight(mapX, mapY, levelNum) < 1 {
+						// short wall is seen over, the walls behind it are drawn above its top
+						shortWalls.hits[shortWalls.count] = shortWallHit{
+							mapX:         mapX,
+							mapY:         mapY,
+							side:         side,
+							perpWallDist: perpWallDist,
+							wallX:        getWallX(rayPosX, rayPosY, rayDirX, rayDirY, side, perpWallDist) - doorOffset,
+						}
+						shortWalls.count++
+						hit = 0
+						doorOffset = 0
+					}
+				}
+			}
+		} else {
+			//hit grid boundary
+			hit = 2
+		}
+	}
+
+	if hit != 1 && shortWalls != nil && shortWalls.count > 0 {
+		// no wall behind the short walls, the farthest one is the wall the ray stopped at
+		shortWalls.count--
+		last := shortWalls.hits[shortWalls.count]
+		for glass != nil && glass.count > 0 && glass.hits[glass.count-1].perpWallDist > last.perpWallDist {
+			glass.count--
+		}
+		return last.mapX, last.mapY, last.side, 1, last.perpWallDist, last.wallX
+	}
+
+	//calculate value of wallX, shifted for the door slide offset
+	wallX = getWallX(rayPosX, rayPosY, rayDirX, rayDirY, side, perpWallDist) - doorOffset
+
+	return mapX, mapY, side, hit, perpWallDist, wallX
+}
+
+// getOffsetWallDist returns the perpendicular distance along the ray to the thin wall inset within the cell
+// at the offset from the cell edge along the X-axis (side 0) or Y-axis (side 1)
+func getOffsetWallDist(rayPosX, rayPosY, rayDirX, rayDirY float64, mapX, mapY int, offset float64, side int) (float64, bool) {
+	if side == 0 {
+		if rayDirX == 0 {
+			return 0, false
+		}
+		return (float64(mapX) + offset - rayPosX) / rayDirX, true
+	}
+
+	if rayDirY == 0 {
+		return 0, false
+	}
+	return (float64(mapY) + offset - rayPosY) / rayDirY, true
+}
+
+// getWallX returns where exactly along the wall (0.0 - 1.0) the ray hit
+func getWallX(rayPosX, rayPosY, rayDirX, rayDirY float64, side int, perpWallDist float64) float64 {
+	var wallX float64
+	if side == 0 {
+		wallX = rayPosY + perpWallDist*rayDirY
+	} else {
+		wallX = rayPosX + perpWallDist*rayDirX
+	}
+	return wallX - math.Floor(wallX)
+}
+
+// getWallTexX returns the x coordinate on the wall texture where the ray hit
+func (c *Camera) getWallTexX(wallX float64, side int, rayDirX, rayDirY float64) int {
+	texX := int(wallX * float64(c.texSize))
+	if side == 0 && rayDirX > 0 {
+		texX = c.texSize - texX - 1
+	}
+
+	if side == 1 && rayDirY < 0 {
+		texX = c.texSize - texX - 1
+	}
+	return texX
+}
+
+// TraceCells returns the map coordinates of each cell on the first level that the line segment
+// from one position to another passes through, in order starting from the cell of the from position.
+// When stopAtSolid is true, the trace stops at (and includes) the first cell containing a wall.
+// Cells outside of the map end the trace.
+func (c *Camera) TraceCells(from, to *geom.Vector2, stopAtSolid bool) []image.Point {
+	grid := c.mapObj.Level(0)
+
+	var cells []image.Point
+	c.traceCells(from, to, func(x, y int) bool {
+		cells = append(cells, image.Pt(x, y))
+		return !stopAtSolid || grid[x][y] <= 0
+	})
+	return cells
+}
+
+// traceCells performs DDA along the line segment between the positions, calling visit for each cell within
+// the map bounds until visit returns false, the cell of the to position is reached, or the trace leaves the map
+func (c *Camera) traceCells(from, to *geom.Vector2, visit func(x, y int) bool) {
+	mapX, mapY := int(math.Floor(from.X)), int(math.Floor(from.Y))
+	endX, endY := int(math.Floor(to.X)), int(math.Floor(to.Y))
+
+	//direction of the segment, so that the distances below are fractions of the segment length
+	dirX, dirY := to.X-from.X, to.Y-from.Y
+
+	//length of segment from one x or y-side to next x or y-side
+	deltaDistX := math.Abs(1 / dirX)
+	deltaDistY := math.Abs(1 / dirY)
+
+	//what direction to step in x or y-direction and length of segment to the first x or y-side
+	var stepX, stepY int
+	var sideDistX, sideDistY float64
+	if dirX < 0 {
+		stepX = -1
+		sideDistX = (from.X - float64(mapX)) * deltaDistX
+	} else {
+		stepX = 1
+		sideDistX = (float64(mapX) + 1.0 - from.X) * deltaDistX
+	}
+	if dirY < 0 {
+		stepY = -1
+		sideDistY = (from.Y - float64(mapY)) * deltaDistY
+	} else {
+		stepY = 1
+		sideDistY = (float64(mapY) + 1.0 - from.Y) * deltaDistY
+	}
+
+	//a segment parallel to an axis never reaches the next side along the other axis
+	if dirX == 0 {
+		sideDistX = math.Inf(1)
+	}
+	if dirY == 0 {
+		sideDistY = math.Inf(1)
+	}
+
+	for {
+		if mapX < 0 || mapY < 0 || mapX >= c.mapWidth || mapY >= c.mapHeight {
+			return
+		}
+		if !visit(mapX, mapY) || (mapX == endX && mapY == endY) {
+			return
+		}
+
+		//jump to next map square in x or y-direction, unless it is past the end of the segment
+		if sideDistX < sideDistY {
+			if sideDistX > 1 {
+				return
+			}
+			sideDistX += deltaDistX
+			mapX += stepX
+		} else {
+			if sideDistY > 1 {
+				return
+			}
+			sideDistY += deltaDistY
+			mapY += stepY
+		}
+	}
+}
+
+// LineOfSight returns true if no cell containing a wall on the first level lies on the line segment
+// between the positions, false if it is obstructed or either position is outside of the map
+func (c *Camera) LineOfSight(a, b *geom.Vector2) bool {
+	grid := c.mapObj.Level(0)
+	endX, endY := int(math.Floor(b.X)), int(math.Floor(b.Y))
+
+	reachedEnd := false
+	c.traceCells(a, b, func(x, y int) bool {
+		if grid[x][y] > 0 {
+			return false
+		}
+		reachedEnd = x == endX && y == endY
+		return true
+	})
+	return reachedEnd
+}
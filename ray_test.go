@@ -0,0 +1,467 @@
+package raycaster
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"testing"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/harbdog/raycaster-go/geom"
+)
+
+func TestCastRayAxisAligned(t *testing.T) {
+	c := newTestCamera(t, 8, 8)
+	grid := c.mapObj.Level(0)
+
+	tests := []struct {
+		name               string
+		posX, posY         float64
+		dirX, dirY         float64
+		wantMapX, wantMapY int
+		wantSide           int
+		wantDist           float64
+	}{
+		{"0 degrees", 4.5, 4.5, 1, 0, 7, 4, 0, 2.5},
+		{"90 degrees", 4.5, 4.5, 0, 1, 4, 7, 1, 2.5},
+		{"180 degrees", 4.5, 4.5, -1, 0, 0, 4, 0, 3.5},
+		{"270 degrees", 4.5, 4.5, 0, -1, 4, 0, 1, 3.5},
+		// starting exactly on grid lines where the sideDist of the zero ray component would be 0 * Inf
+		{"0 degrees on grid line", 4, 4, 1, 0, 7, 4, 0, 3},
+		{"90 degrees on grid line", 4, 4, 0, 1, 4, 7, 1, 3},
+		{"180 degrees on grid line", 4, 4, -1, 0, 0, 4, 0, 3},
+		{"270 degrees on grid line", 4, 4, 0, -1, 4, 0, 1, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mapX, mapY, side, hit, perpWallDist, wallX := c.castRay(tt.posX, tt.posY, tt.dirX, tt.dirY, grid, 0, nil, nil, false)
+			if hit != 1 {
+				t.Fatalf("hit = %v, want 1", hit)
+			}
+			if mapX != tt.wantMapX || mapY != tt.wantMapY || side != tt.wantSide {
+				t.Errorf("hit cell (%v, %v) side %v, want (%v, %v) side %v", mapX, mapY, side, tt.wantMapX, tt.wantMapY, tt.wantSide)
+			}
+			if perpWallDist != tt.wantDist {
+				t.Errorf("distance = %v, want %v", perpWallDist, tt.wantDist)
+			}
+			if math.IsNaN(wallX) || wallX < 0 || wallX >= 1 {
+				t.Errorf("wallX = %v, want within [0, 1)", wallX)
+			}
+		})
+	}
+}
+
+func TestUpdateAxisAlignedHeadings(t *testing.T) {
+	c := newTestCamera(t, 8, 8)
+	c.SetPosition(&geom.Vector2{X: 4, Y: 4})
+	centerX := testViewWidth / 2
+
+	for _, degrees := range []float64{0, 90, 180, 270} {
+		c.SetHeadingAngle(geom.Radians(degrees))
+		c.Update(nil)
+
+		info := c.ColumnInfo(centerX)
+		if info == nil || info.Texture == nil {
+			t.Fatalf("heading %v: no wall in center column", degrees)
+		}
+		if math.IsNaN(info.Distance) || math.IsInf(info.Distance, 0) || !geom.NearlyEqual(info.Distance, 3, 1e-4) {
+			t.Errorf("heading %v: center distance = %v, want 3", degrees, info.Distance)
+		}
+	}
+}
+
+// newTestTraceCamera returns a camera on an 8x8 map with walls around the edges and at (3, 1) and (5, 5)
+func newTestTraceCamera(t *testing.T) *Camera {
+	t.Helper()
+	grid := newTestGrid(8, 8)
+	grid[3][1] = 1
+	grid[5][5] = 1
+	return NewCamera(testViewWidth, testViewHeight, testTexSize, &testMap{grid: grid}, newTestTextures())
+}
+
+func TestTraceCells(t *testing.T) {
+	c := newTestTraceCamera(t)
+
+	tests := []struct {
+		name        string
+		from, to    geom.Vector2
+		stopAtSolid bool
+		want        []image.Point
+	}{
+		{"along X", geom.Vector2{X: 1.5, Y: 2.5}, geom.Vector2{X: 4.5, Y: 2.5}, true,
+			[]image.Point{{1, 2}, {2, 2}, {3, 2}, {4, 2}}},
+		{"along Y reversed", geom.Vector2{X: 2.5, Y: 5.5}, geom.Vector2{X: 2.5, Y: 2.5}, true,
+			[]image.Point{{2, 5}, {2, 4}, {2, 3}, {2, 2}}},
+		{"diagonal through corners", geom.Vector2{X: 1.5, Y: 2.5}, geom.Vector2{X: 3.5, Y: 4.5}, true,
+			[]image.Point{{1, 2}, {1, 3}, {2, 3}, {2, 4}, {3, 4}}},
+		{"diagonal", geom.Vector2{X: 1.2, Y: 2.5}, geom.Vector2{X: 3.8, Y: 3.5}, true,
+			[]image.Point{{1, 2}, {2, 2}, {2, 3}, {3, 3}}},
+		{"same cell", geom.Vector2{X: 2.2, Y: 2.2}, geom.Vector2{X: 2.8, Y: 2.8}, true,
+			[]image.Point{{2, 2}}},
+		{"blocked stops at wall", geom.Vector2{X: 1.5, Y: 1.5}, geom.Vector2{X: 5.5, Y: 1.5}, true,
+			[]image.Point{{1, 1}, {2, 1}, {3, 1}}},
+		{"blocked passes through wall", geom.Vector2{X: 1.5, Y: 1.5}, geom.Vector2{X: 5.5, Y: 1.5}, false,
+			[]image.Point{{1, 1}, {2, 1}, {3, 1}, {4, 1}, {5, 1}}},
+		{"end outside map", geom.Vector2{X: 1.5, Y: 2.5}, geom.Vector2{X: -2.5, Y: 2.5}, false,
+			[]image.Point{{1, 2}, {0, 2}}},
+		{"start outside map", geom.Vector2{X: -1.5, Y: 2.5}, geom.Vector2{X: 1.5, Y: 2.5}, false,
+			nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := c.TraceCells(&tt.from, &tt.to, tt.stopAtSolid)
+			if len(got) != len(tt.want) {
+				t.Fatalf("cells = %v, want %v", got, tt.want)
+			}
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Fatalf("cells = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestLineOfSight(t *testing.T) {
+	c := newTestTraceCamera(t)
+
+	tests := []struct {
+		name string
+		a, b geom.Vector2
+		want bool
+	}{
+		{"clear along X", geom.Vector2{X: 1.5, Y: 2.5}, geom.Vector2{X: 6.5, Y: 2.5}, true},
+		{"clear diagonal", geom.Vector2{X: 1.5, Y: 2.5}, geom.Vector2{X: 4.5, Y: 5.5}, true},
+		{"clear passing beside wall", geom.Vector2{X: 4.5, Y: 4.5}, geom.Vector2{X: 6.5, Y: 4.5}, true},
+		{"same cell", geom.Vector2{X: 2.2, Y: 2.2}, geom.Vector2{X: 2.8, Y: 2.8}, true},
+		{"fully blocked", geom.Vector2{X: 1.5, Y: 1.5}, geom.Vector2{X: 5.5, Y: 1.5}, false},
+		{"partially blocked by wall corner", geom.Vector2{X: 4.5, Y: 4.2}, geom.Vector2{X: 6.5, Y: 5.8}, false},
+		{"end inside wall", geom.Vector2{X: 4.5, Y: 4.5}, geom.Vector2{X: 5.5, Y: 5.5}, false},
+		{"end outside map", geom.Vector2{X: 1.5, Y: 2.5}, geom.Vector2{X: -2.5, Y: 2.5}, false},
+		{"start outside map", geom.Vector2{X: -1.5, Y: 2.5}, geom.Vector2{X: 1.5, Y: 2.5}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := c.LineOfSight(&tt.a, &tt.b); got != tt.want {
+				t.Errorf("LineOfSight = %v, want %v", got, tt.want)
+			}
+			if got := c.LineOfSight(&tt.b, &tt.a); got != tt.want {
+				t.Errorf("reversed LineOfSight = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFisheyeCorrectionExtremes(t *testing.T) {
+	c := newTestCamera(t, 8, 8)
+	c.SetPosition(&geom.Vector2{X: 4, Y: 4})
+	texture := ebiten.NewImage(testTexSize, testTexSize)
+	sprites := []Sprite{newTestSprite(6, 4, texture), newTestSprite(6, 5, texture)}
+	centerX, edgeX := testViewWidth/2, 1
+
+	lineHeight := func(x int) int {
+		info := c.ColumnInfo(x)
+		if info == nil || info.Texture == nil {
+			t.Fatalf("no wall in column %v", x)
+		}
+		return info.DrawEnd - info.DrawStart
+	}
+	spriteWidth := func(sprite Sprite) int {
+		return len(castSpriteStripes(c, sprite))
+	}
+
+	// full correction projects the flat wall in front of the camera at the same height in every column
+	c.SetFisheyeCorrection(1)
+	c.Update(sprites)
+	correctedCenter, correctedEdge := lineHeight(centerX), lineHeight(edgeX)
+	if correctedCenter != correctedEdge {
+		t.Errorf("corrected line heights %v at center and %v at edge, want equal", correctedCenter, correctedEdge)
+	}
+	correctedDepths := c.Depths()
+	correctedCenterSprite, correctedSideSprite := spriteWidth(sprites[0]), spriteWidth(sprites[1])
+
+	// no correction projects by the real distance, so the wall curves away towards the edges
+	c.SetFisheyeCorrection(0)
+	c.Update(sprites)
+	if got := lineHeight(centerX); got != correctedCenter {
+		t.Errorf("uncorrected center line height = %v, want %v", got, correctedCenter)
+	}
+	if got := lineHeight(edgeX); got >= correctedEdge {
+		t.Errorf("uncorrected edge line height = %v, want less than %v", got, correctedEdge)
+	}
+	// the sprite in front of the camera keeps its size, the one to the side shrinks
+	if got := spriteWidth(sprites[0]); got != correctedCenterSprite {
+		t.Errorf("uncorrected center sprite width = %v, want %v", got, correctedCenterSprite)
+	}
+	if got := spriteWidth(sprites[1]); got >= correctedSideSprite {
+		t.Errorf("uncorrected side sprite width = %v, want less than %v", got, correctedSideSprite)
+	}
+
+	// depth used for occlusion is the perpendicular distance either way
+	for x, depth := range c.Depths() {
+		if depth != correctedDepths[x] {
+			t.Fatalf("column %v: uncorrected depth = %v, want %v", x, depth, correctedDepths[x])
+		}
+	}
+
+	// amounts beyond the extremes are clamped to them
+	c.SetFisheyeCorrection(-1)
+	if c.fisheyeCorrection != 0 {
+		t.Errorf("fisheye correction = %v, want 0", c.fisheyeCorrection)
+	}
+	c.SetFisheyeCorrection(2)
+	if c.fisheyeCorrection != 1 {
+		t.Errorf("fisheye correction = %v, want 1", c.fisheyeCorrection)
+	}
+}
+
+// testCellFloorTextures is a test texture handler with a different floor texture for cells before and after X 4
+type testCellFloorTextures struct {
+	*testTextures
+	nearFloor, farFloor *image.RGBA
+}
+
+func (t *testCellFloorTextures) FloorTextureAt(x, y int) *image.RGBA {
+	if x < 4 {
+		return t.nearFloor
+	}
+	return t.farFloor
+}
+
+// newTestFloorTexture returns a floor texture filled with the color
+func newTestFloorTexture(clr color.RGBA) *image.RGBA {
+	tex := image.NewRGBA(image.Rect(0, 0, testTexSize, testTexSize))
+	draw.Draw(tex, tex.Bounds(), &image.Uniform{C: clr}, image.Point{}, draw.Src)
+	return tex
+}
+
+func TestCastFloorTexturePerCell(t *testing.T) {
+	textures := &testCellFloorTextures{
+		testTextures: newTestTextures(),
+		nearFloor:    newTestFloorTexture(color.RGBA{R: 255, A: 255}),
+		farFloor:     newTestFloorTexture(color.RGBA{B: 255, A: 255}),
+	}
+	pos := &geom.Vector2{X: 1.5, Y: 4.5}
+	c, err := NewCameraAt(testViewWidth, testViewHeight, testTexSize, &testMap{grid: newTestGrid(8, 8)}, textures, pos, 0)
+	if err != nil {
+		t.Fatalf("NewCameraAt: %v", err)
+	}
+	c.Update(nil)
+
+	// follow the floor straight ahead of the camera up the center column, from the bottom of the view to the wall
+	centerX := testViewWidth / 2
+	wallEnd := c.ColumnInfo(centerX).DrawEnd
+	var nearRows, farRows int
+	for y := c.h - 1; y >= wallEnd; y-- {
+		floorX := pos.X + float64(c.h)/float64(2*y-c.h)
+		if math.Abs(floorX-4) < 0.1 {
+			// too close to the edge between the cells to tell which one is sampled
+			continue
+		}
+
+		pixel := c.floorLvl.horBuffer.RGBAAt(centerX, y)
+		if floorX < 4 {
+			nearRows++
+			if pixel.R == 0 || pixel.B != 0 {
+				t.Errorf("row %v at floor X %v: pixel %v, want near floor texture", y, floorX, pixel)
+			}
+		} else {
+			farRows++
+			if pixel.B == 0 || pixel.R != 0 {
+				t.Errorf("row %v at floor X %v: pixel %v, want far floor texture", y, floorX, pixel)
+			}
+		}
+	}
+
+	if nearRows == 0 || farRows == 0 {
+		t.Errorf("%v rows of near floor and %v rows of far floor, want both", nearRows, farRows)
+	}
+}
+
+// testHeightMap is a test map where the walls at X 7 have the given height
+type testHeightMap struct {
+	testMap
+	height float64
+}
+
+func (m *testHeightMap) WallHeight(x, y, levelNum int) float64 {
+	if x == 7 {
+		return m.height
+	}
+	return 1
+}
+
+func TestCastCeilingToWallHeight(t *testing.T) {
+	// a row of walls across the map at X 7, with room for the ceiling to continue past them
+	grid := newTestGrid(16, 8)
+	for y := range grid[7] {
+		grid[7][y] = 1
+	}
+	textures := newTestTextures()
+	ceiling := newTestFloorTexture(color.RGBA{R: 255, G: 255, B: 255, A: 255})
+
+	for _, height := range []float64{1, 2} {
+		mapObj := &testHeightMap{testMap{grid: grid}, height}
+		c, err := NewCameraAt(testViewWidth, testViewHeight, testTexSize, mapObj, textures, &geom.Vector2{X: 4, Y: 4.5}, 0)
+		if err != nil {
+			t.Fatalf("NewCameraAt: %v", err)
+		}
+		c.SetCeilingTexture(ceiling)
+		c.Update(nil)
+
+		centerX := testViewWidth / 2
+		info := c.ColumnInfo(centerX)
+		lineHeight := int(float64(c.h) / info.Distance)
+		if want := info.DrawEnd - int(float64(lineHeight)*height); info.DrawStart != want {
+			t.Fatalf("height %v: wall drawn from row %v, want %v", height, info.DrawStart, want)
+		}
+
+		// no ceiling is cast behind the wall
+		for y := geom.MaxInt(info.DrawStart, 0); y < info.DrawEnd; y++ {
+			if pixel := c.floorLvl.horBuffer.RGBAAt(centerX, y); pixel.A != 0 {
+				t.Fatalf("height %v: ceiling pixel %v cast behind the wall at row %v", height, pixel, y)
+			}
+		}
+
+		// the ceiling is cast down to the top of the wall
+		if y := info.DrawStart - 1; y >= 0 {
+			if pixel := c.floorLvl.horBuffer.RGBAAt(centerX, y); pixel.A == 0 {
+				t.Errorf("height %v: no ceiling cast above the wall at row %v", height, y)
+			}
+		}
+	}
+}
+
+func TestCastShortWall(t *testing.T) {
+	// a row of half height walls across the map at X 7, in front of the map edge walls at X 15
+	grid := newTestGrid(16, 8)
+	for y := range grid[7] {
+		grid[7][y] = 1
+	}
+	mapObj := &testHeightMap{testMap{grid: grid}, 0.5}
+	c, err := NewCameraAt(testViewWidth, testViewHeight, testTexSize, mapObj, newTestTextures(), &geom.Vector2{X: 4, Y: 4.5}, 0)
+	if err != nil {
+		t.Fatalf("NewCameraAt: %v", err)
+	}
+	sprite := newTestSprite(10, 4.5, ebiten.NewImage(testTexSize, testTexSize))
+	c.Update([]Sprite{sprite})
+
+	// the wall behind is seen over the short wall
+	centerX := testViewWidth / 2
+	info := c.ColumnInfo(centerX)
+	if info.MapX != 15 || !geom.NearlyEqual(info.Distance, 11, 1e-9) {
+		t.Fatalf("column hit wall %v, %v at %v, want the wall behind the short wall", info.MapX, info.MapY, info.Distance)
+	}
+
+	shortWallLvl := c.shortWallLvls[0][0]
+	if shortWallLvl.CurrTex[centerX] == nil {
+		t.Fatalf("short wall not cast")
+	}
+	shortWallTop := shortWallLvl.Sv[centerX].Min.Y
+	lineHeight := int(float64(c.h) / 3)
+	if want := shortWallLvl.Sv[centerX].Max.Y - lineHeight/2; shortWallTop != want {
+		t.Errorf("short wall drawn from row %v, want %v", shortWallTop, want)
+	}
+	if info.DrawStart >= shortWallTop {
+		t.Errorf("wall behind drawn from row %v, hidden by the short wall from row %v", info.DrawStart, shortWallTop)
+	}
+	if c.shortWallLvls[0][1].CurrTex[centerX] != nil {
+		t.Errorf("second short wall layer cast with only one short wall")
+	}
+
+	// the sprite behind the short wall is clipped to its top
+	spriteLvl := castSpriteLevel(c, sprite)
+	if spriteLvl == nil || spriteLvl.CurrTex[centerX] == nil {
+		t.Fatalf("sprite above the short wall not cast")
+	}
+	sv, cts := spriteLvl.Sv[centerX], spriteLvl.Cts[centerX]
+	if sv.Max.Y != shortWallTop || sv.Min.Y >= sv.Max.Y {
+		t.Errorf("sprite drawn to rows %v - %v, want clipped to the short wall top %v", sv.Min.Y, sv.Max.Y, shortWallTop)
+	}
+	if cts.Dy() <= 0 || cts.Dy() >= testTexSize {
+		t.Errorf("sprite texture rows %v - %v, want the part above the short wall", cts.Min.Y, cts.Max.Y)
+	}
+}
+
+// testGlassMap is a map where walls in the glass columns are glass
+type testGlassMap struct {
+	testMap
+	glassX []int
+}
+
+func (m *testGlassMap) WallGlass(x, y, levelNum int) (color.RGBA, bool) {
+	for _, glassX := range m.glassX {
+		if x == glassX {
+			return color.RGBA{R: 128, G: 192, B: 255, A: 96}, true
+		}
+	}
+	return color.RGBA{}, false
+}
+
+func TestCastGlassLayersAroundSprites(t *testing.T) {
+	// walls of glass across the map at X 4 and 6, in front of the edge wall at X 9
+	grid := newTestGrid(10, 8)
+	for y := range grid[0] {
+		grid[4][y], grid[6][y] = 1, 1
+	}
+	mapObj := &testGlassMap{testMap{grid: grid}, []int{4, 6}}
+	c, err := NewCameraAt(testViewWidth, testViewHeight, testTexSize, mapObj, newTestTextures(), &geom.Vector2{X: 2.5, Y: 4.5}, 0)
+	if err != nil {
+		t.Fatalf("NewCameraAt: %v", err)
+	}
+
+	texture := ebiten.NewImage(testTexSize, testTexSize)
+	between := newTestSprite(5.5, 4.5, texture)
+	// in front of the first glass wall by perpendicular depth, but farther from the camera than it
+	aside := newTestSprite(3.9, 5.2, texture)
+	sprites := []Sprite{between, aside}
+	c.Update(sprites)
+
+	centerX := testViewWidth / 2
+	if d := c.DepthAt(centerX); math.Abs(d-6.5) > 1e-9 {
+		t.Fatalf("wall depth %v, want 6.5 behind the glass", d)
+	}
+	for layer, want := range []float64{1.5, 3.5, -1, -1} {
+		if got := c.glassDepth[layer][centerX]; math.Abs(got-want) > 1e-9 {
+			t.Errorf("glass layer %v depth %v, want %v", layer, got, want)
+		}
+		if drawn := c.glassLvls[0][layer].CurrTex[centerX] != nil; drawn != (want > 0) {
+			t.Errorf("glass layer %v cast %v, want %v", layer, drawn, want > 0)
+		}
+	}
+
+	screen := ebiten.NewImage(testViewWidth, testViewHeight)
+	tests := []struct {
+		name      string
+		sprite    *testSprite
+		wantDepth float64
+		// next glass layer left to draw in front of the sprite
+		wantLayer int
+	}{
+		{"between glass walls", between, 3, 0},
+		{"in front of glass walls", aside, 1.4, -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ordIndex := c.getSpriteOrdIndex(tt.sprite)
+			if ordIndex < 0 || tt.sprite.screenRect == nil {
+				t.Fatalf("sprite not cast")
+			}
+
+			depth := c.spriteDepth[ordIndex]
+			if math.Abs(depth-tt.wantDepth) > 1e-9 {
+				t.Errorf("sprite depth %v, want perpendicular depth %v", depth, tt.wantDepth)
+			}
+
+			x := (tt.sprite.screenRect.Min.X + tt.sprite.screenRect.Max.X) / 2
+			if got := c.drawGlassBehind(screen, x, maxGlassLayers-1, depth); got != tt.wantLayer {
+				t.Errorf("glass drawn behind the sprite up to layer %v, want %v", got, tt.wantLayer)
+			}
+		})
+	}
+}
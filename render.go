@@ -3,52 +3,175 @@ package raycaster
 import (
 	"image"
 	"image/color"
+	"math"
 
 	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/harbdog/raycaster-go/geom"
 )
 
 // Draw the raycasted camera view to the screen.
 func (c *Camera) Draw(screen *ebiten.Image) {
-	screen.Clear()
+	if c.closed {
+		return
+	}
+
+	if c.renderImage == nil {
+		c.drawView(screen)
+	} else {
+		// raycasted view is at a different resolution, scale it to fit the viewport
+		c.drawView(c.renderImage)
+
+		op := &ebiten.DrawImageOptions{}
+		op.Filter = ebiten.FilterNearest
+		op.GeoM.Scale(float64(c.viewW)/float64(c.w), float64(c.viewH)/float64(c.h))
+		screen.DrawImage(c.renderImage, op)
+	}
 
-	// draw textured floor
-	screen.ReplacePixels(c.floorLvl.horBuffer.Pix)
+	//--draw overlays on top of everything else--//
+	c.drawOverlays(screen)
+}
+
+// drawView draws the raycasted camera view to an image the size of the raycasted view
+func (c *Camera) drawView(screen *ebiten.Image) {
+	// fill with the background color, shown where there is no sky, floor, or wall (transparent by default)
+	screen.Fill(c.backgroundColor)
 
 	//--draw basic sky and floor--//
 	texRect := image.Rect(0, 0, c.texSize, c.texSize)
 	lightingRGBA := &color.RGBA{R: c.maxLightRGB.R, G: c.maxLightRGB.G, B: c.maxLightRGB.B, A: 255}
 
-	floorRect := image.Rect(0, int(float64(c.h)*0.5)+c.pitch,
+	floorRect := image.Rect(0, int(float64(c.h)*0.5)+c.pose.pitch,
 		c.w, c.h)
-	drawTexture(screen, c.floor, &floorRect, &texRect, lightingRGBA)
+	c.drawTexture(screen, c.floor, &floorRect, &texRect, lightingRGBA, 0)
+
+	skyRect := image.Rect(0, 0, c.w, int(float64(c.h)*0.5)+c.pose.pitch)
+	skyTexRect := c.getSkyTexRect(texRect, skyRect.Dy())
+	c.drawSky(screen, &skyRect, &skyTexRect, lightingRGBA)
 
-	skyRect := image.Rect(0, 0, c.w, int(float64(c.h)*0.5)+c.pitch)
-	drawTexture(screen, c.sky, &skyRect, &texRect, lightingRGBA)
+	//--draw textured floor and ceiling--//
+	c.floorLvl.image.ReplacePixels(c.floorLvl.horBuffer.Pix)
+	screen.DrawImage(c.floorLvl.image, nil)
 
 	//--draw walls--//
 	for x := 0; x < c.w; x++ {
 		for i := cap(c.levels) - 1; i >= 0; i-- {
-			drawTexture(screen, c.levels[i].CurrTex[x], c.levels[i].Sv[x], c.levels[i].Cts[x], c.levels[i].St[x])
+			c.drawWall(screen, x, c.levels[i], c.decalLvls[i][0])
+			// short walls in front of the wall are drawn over it from the farthest
+			for layer := maxShortWalls - 1; layer >= 0; layer-- {
+				c.drawWall(screen, x, c.shortWallLvls[i][layer], c.decalLvls[i][layer+1])
+			}
+			if i > 0 {
+				// glass of upper levels is drawn right over its level since sprites are only on the first level
+				for layer := maxGlassLayers - 1; layer >= 0; layer-- {
+					c.drawGlass(screen, x, i, layer)
+				}
+			}
 		}
 	}
 
-	// draw sprites
+	// draw sprites from far to close, with the first level glass in between sprites behind and in front of it
 	for x := 0; x < c.w; x++ {
+		glassLayer := maxGlassLayers - 1
 		for i := 0; i < cap(c.spriteLvls); i++ {
 			spriteLvl := c.spriteLvls[i]
 			if spriteLvl == nil {
 				continue
 			}
 
+			if i < len(c.spriteDepth) {
+				glassLayer = c.drawGlassBehind(screen, x, glassLayer, c.spriteDepth[i])
+			}
+
 			texture := spriteLvl.CurrTex[x]
 			if texture != nil {
-				drawTexture(screen, texture, spriteLvl.Sv[x], spriteLvl.Cts[x], spriteLvl.St[x])
+				c.drawTexture(screen, texture, spriteLvl.Sv[x], spriteLvl.Cts[x], spriteLvl.St[x], spriteLvl.Sf[x])
 			}
 		}
+		c.drawGlassBehind(screen, x, glassLayer, math.Inf(-1))
+	}
+}
+
+// drawWall draws the wall slice of the level at the column with the wall decals over it
+func (c *Camera) drawWall(screen *ebiten.Image, x int, lvl *level, decalLayers []*level) {
+	c.drawTexture(screen, lvl.CurrTex[x], lvl.Sv[x], lvl.Cts[x], lvl.St[x], lvl.Sf[x])
+	for _, decalLvl := range decalLayers {
+		c.drawTexture(screen, decalLvl.CurrTex[x], decalLvl.Sv[x], decalLvl.Cts[x], decalLvl.St[x], decalLvl.Sf[x])
+	}
+}
+
+// drawGlassBehind draws the first level glass layers of the column from the given layer towards the camera
+// while they are farther than the perpendicular depth, returns the next glass layer left to draw
+func (c *Camera) drawGlassBehind(screen *ebiten.Image, x, layer int, depth float64) int {
+	for ; layer >= 0; layer-- {
+		glassDepth := c.glassDepth[layer][x]
+		if glassDepth < 0 {
+			// no glass wall in this layer
+			continue
+		}
+		if glassDepth <= depth {
+			break
+		}
+		c.drawGlass(screen, x, 0, layer)
 	}
+	return layer
+}
+
+// drawGlass draws the glass wall slice of the level and glass layer at the column
+func (c *Camera) drawGlass(screen *ebiten.Image, x, levelNum, layer int) {
+	glassLvl := c.glassLvls[levelNum][layer]
+	c.drawTexture(screen, glassLvl.CurrTex[x], glassLvl.Sv[x], glassLvl.Cts[x], glassLvl.St[x], glassLvl.Sf[x])
 }
 
-func drawTexture(screen *ebiten.Image, texture *ebiten.Image, destinationRectangle *image.Rectangle, sourceRectangle *image.Rectangle, color *color.RGBA) {
+// drawSky draws the sky texture, scrolled horizontally with the camera heading when the sky scroll factor is set
+func (c *Camera) drawSky(screen *ebiten.Image, skyRect *image.Rectangle, texRect *image.Rectangle, lightingRGBA *color.RGBA) {
+	if c.sky == nil || c.skyScrollFactor == 0 {
+		c.drawTexture(screen, c.sky, skyRect, texRect, lightingRGBA, 0)
+		return
+	}
+
+	// offset the sky texture proportional to the heading angle, wrapping around at the texture edge
+	skyWidth := c.sky.Bounds().Dx()
+	texWidth := geom.MinInt(texRect.Dx(), skyWidth)
+	offsetX := int(-c.pose.headingAngle/geom.Pi2*float64(skyWidth)*c.skyScrollFactor) % skyWidth
+	if offsetX < 0 {
+		offsetX += skyWidth
+	}
+
+	// draw from the offset up to the edge of the sky texture
+	firstWidth := geom.MinInt(texWidth, skyWidth-offsetX)
+	firstSrcRect := image.Rect(offsetX, texRect.Min.Y, offsetX+firstWidth, texRect.Max.Y)
+	firstDstRect := image.Rect(skyRect.Min.X, skyRect.Min.Y,
+		skyRect.Min.X+skyRect.Dx()*firstWidth/texWidth, skyRect.Max.Y)
+	c.drawTexture(screen, c.sky, &firstDstRect, &firstSrcRect, lightingRGBA, 0)
+
+	if firstWidth < texWidth {
+		// draw the remainder wrapped around from the start of the sky texture
+		wrapSrcRect := image.Rect(0, texRect.Min.Y, texWidth-firstWidth, texRect.Max.Y)
+		wrapDstRect := image.Rect(firstDstRect.Max.X, skyRect.Min.Y, skyRect.Max.X, skyRect.Max.Y)
+		c.drawTexture(screen, c.sky, &wrapDstRect, &wrapSrcRect, lightingRGBA, 0)
+	}
+}
+
+// getSkyTexRect returns the area of the sky texture shown above the horizon. When the sky tilt is set,
+// the sky texture bottom stays at the horizon and more of it is revealed as the camera pitches up,
+// otherwise the whole sky texture is stretched to fit above the horizon.
+func (c *Camera) getSkyTexRect(texRect image.Rectangle, skyHeight int) image.Rectangle {
+	if c.skyTilt <= 0 || skyHeight <= 0 {
+		return texRect
+	}
+
+	// screen height of the whole sky texture, so that looking fully up reveals its top
+	fullSkyHeight := float64(c.h)*0.5 + float64(c.h)*c.fovDepth*c.skyTilt
+	visible := math.Min(float64(skyHeight)/fullSkyHeight, 1)
+
+	texRect.Min.Y = texRect.Max.Y - int(float64(texRect.Dy())*visible)
+	if texRect.Min.Y >= texRect.Max.Y {
+		texRect.Min.Y = texRect.Max.Y - 1
+	}
+	return texRect
+}
+
+func (c *Camera) drawTexture(screen *ebiten.Image, texture *ebiten.Image, destinationRectangle *image.Rectangle, sourceRectangle *image.Rectangle, color *color.RGBA, fog float64) {
 	if texture == nil || destinationRectangle == nil || sourceRectangle == nil {
 		return
 	}
@@ -64,7 +187,7 @@ func drawTexture(screen *ebiten.Image, texture *ebiten.Image, destinationRectang
 	}
 
 	op := &ebiten.DrawImageOptions{}
-	op.Filter = ebiten.FilterNearest
+	op.Filter = c.textureFilter
 
 	op.GeoM.Scale(scaleX, scaleY)
 	op.GeoM.Translate(float64(destinationRectangle.Min.X), float64(destinationRectangle.Min.Y))
@@ -72,8 +195,13 @@ func drawTexture(screen *ebiten.Image, texture *ebiten.Image, destinationRectang
 	destTexture := texture.SubImage(*sourceRectangle).(*ebiten.Image)
 
 	if color != nil {
-		// color channel modulation/tinting
-		op.ColorM.Scale(float64(color.R)/255, float64(color.G)/255, float64(color.B)/255, float64(color.A)/255)
+		// color channel modulation/tinting, reduced by the amount the fog color is blended in
+		op.ColorM.Scale((1-fog)*float64(color.R)/255, (1-fog)*float64(color.G)/255, (1-fog)*float64(color.B)/255, float64(color.A)/255)
+	}
+
+	if fog > 0 {
+		// blend towards fog color
+		op.ColorM.Translate(fog*float64(c.fogRGBA.R)/255, fog*float64(c.fogRGBA.G)/255, fog*float64(c.fogRGBA.B)/255, 0)
 	}
 
 	screen.DrawImage(destTexture, op)
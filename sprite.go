@@ -2,6 +2,8 @@ package raycaster
 
 import (
 	"image"
+	"image/color"
+	"math"
 
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/harbdog/raycaster-go/geom"
@@ -33,6 +35,84 @@ type Sprite interface {
 	IsFocusable() bool
 }
 
+// DirectionalSprite is an optional interface a Sprite can implement to render
+// a different texture rectangle depending on the angle it is viewed from (e.g. 8-way rotating enemies)
+type DirectionalSprite interface {
+	// TextureRectForAngle needs to return the rectangle of the texture coordinates to draw,
+	// given the angle (radians) of the line from the sprite position to the camera position.
+	// The sprite can compare it against its own heading to determine which side is facing the camera.
+	// Used instead of TextureRect.
+	TextureRectForAngle(relAngle float64) image.Rectangle
+}
+
+// TintedSprite is an optional interface a Sprite can implement to modulate its color
+// (e.g. flash red when damaged or glow when powered up)
+type TintedSprite interface {
+	// ColorMod needs to return the color multiplied into the sprite after distance based lighting
+	// (white for no color modulation)
+	ColorMod() color.RGBA
+}
+
+// TranslucentSprite is an optional interface a Sprite can implement to be rendered
+// partially transparent (e.g. smoke, ghosts)
+type TranslucentSprite interface {
+	// Opacity needs to return the opacity of the sprite, from 0.0 (invisible) to 1.0 (opaque)
+	Opacity() float64
+}
+
+// StretchedSprite is an optional interface a Sprite can implement to scale its width and height independently
+// (e.g. tall banners or wide posters)
+type StretchedSprite interface {
+	// ScaleXY needs to return the horizontal and vertical scale factors, used instead of Scale
+	ScaleXY() (scaleX, scaleY float64)
+}
+
+// getSpriteScale returns the horizontal and vertical scale factors of the sprite
+func getSpriteScale(sprite Sprite) (scaleX, scaleY float64) {
+	if stretchedSprite, ok := sprite.(StretchedSprite); ok {
+		return stretchedSprite.ScaleXY()
+	}
+	scale := sprite.Scale()
+	return scale, scale
+}
+
+// FlippedSprite is an optional interface a Sprite can implement to mirror its texture horizontally
+// (e.g. reusing one side's art for the opposite facing of a directional sprite)
+type FlippedSprite interface {
+	// FlipHorizontal needs to return true if the texture rectangle should be drawn mirrored horizontally
+	FlipHorizontal() bool
+}
+
+// OrderedSprite is an optional interface a Sprite can implement to control its draw order
+// relative to other sprites at the same distance (e.g. a pickup always drawn over its glow aura)
+type OrderedSprite interface {
+	// RenderOrder needs to return the render order of the sprite, sprites at the same distance
+	// with a higher render order are drawn on top of those with a lower render order (default 0)
+	RenderOrder() int
+}
+
+// LeveledSprite is an optional interface a Sprite can implement to be positioned on an upper elevation level
+// (e.g. enemies on the second floor of a multi-story map)
+type LeveledSprite interface {
+	// LevelNum needs to return the elevation level the sprite is on, its Z-position is relative to
+	// the bottom of that level (default 0)
+	LevelNum() int
+}
+
+// getSpritePosZ returns the Z-position of the sprite relative to the bottom of the first level
+func getSpritePosZ(sprite Sprite) float64 {
+	if leveledSprite, ok := sprite.(LeveledSprite); ok {
+		// each level is one unit of Z-position tall, matching the walls stacked by castLevel
+		return sprite.PosZ() + float64(leveledSprite.LevelNum())
+	}
+	return sprite.PosZ()
+}
+
+// getSpriteLevelNum returns the elevation level the sprite is on, from its Z-position relative to the first level
+func getSpriteLevelNum(sprite Sprite) int {
+	return geom.MaxInt(int(math.Floor(getSpritePosZ(sprite))), 0)
+}
+
 type SpriteAnchor int
 
 const (
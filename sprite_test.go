@@ -0,0 +1,421 @@
+package raycaster
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/harbdog/raycaster-go/geom"
+)
+
+// castSpriteLevel returns the level the sprite was cast to during the last update, or nil if it was not drawn
+func castSpriteLevel(c *Camera, sprite Sprite) *level {
+	for i, spriteIndex := range c.spriteOrder {
+		if c.sprites[spriteIndex] == sprite {
+			return c.spriteLvls[i]
+		}
+	}
+	return nil
+}
+
+// castSpriteStripes returns the screen columns the sprite was cast to during the last update
+func castSpriteStripes(c *Camera, sprite Sprite) []int {
+	spriteLvl := castSpriteLevel(c, sprite)
+	if spriteLvl == nil {
+		return nil
+	}
+
+	var stripes []int
+	for x, tex := range spriteLvl.CurrTex {
+		if tex != nil {
+			stripes = append(stripes, x)
+		}
+	}
+	return stripes
+}
+
+// testAtlasSprite is a test sprite drawn from a frame within its texture
+type testAtlasSprite struct {
+	*testSprite
+	frame image.Rectangle
+}
+
+func (s *testAtlasSprite) TextureRect() image.Rectangle {
+	return s.frame
+}
+
+func TestCastTallSpriteNotTruncated(t *testing.T) {
+	c := newTestCamera(t, 8, 8)
+	sprite := newTestSprite(6.5, 4, ebiten.NewImage(32, 64))
+	c.Update([]Sprite{sprite})
+
+	stripes := castSpriteStripes(c, sprite)
+	if len(stripes) == 0 {
+		t.Fatal("sprite not cast")
+	}
+
+	spriteLvl := castSpriteLevel(c, sprite)
+	screenHeight := spriteLvl.Sv[stripes[0]].Dy()
+	// texture rows are rounded to screen pixels, which can leave out up to a pixel at either end
+	tolerance := 2*64/screenHeight + 1
+	for _, x := range stripes {
+		texRect := spriteLvl.Cts[x]
+		// the whole texture height is sampled
+		if texRect.Min.Y < 0 || texRect.Min.Y > tolerance || texRect.Max.Y > 64 || texRect.Max.Y < 64-tolerance {
+			t.Fatalf("column %v: texture rows %v to %v, want 0 to 64", x, texRect.Min.Y, texRect.Max.Y)
+		}
+		if texRect.Min.X < 0 || texRect.Max.X > 32 {
+			t.Fatalf("column %v: texture columns %v to %v outside of the texture width", x, texRect.Min.X, texRect.Max.X)
+		}
+	}
+
+	// the whole texture width is sampled across the stripes
+	tolerance = 2*32/len(stripes) + 1
+	if first, last := spriteLvl.Cts[stripes[0]].Min.X, spriteLvl.Cts[stripes[len(stripes)-1]].Min.X; first > tolerance || last < 31-tolerance {
+		t.Errorf("texture columns %v to %v, want 0 to 31", first, last)
+	}
+}
+
+func TestCastSpriteAtlasFrame(t *testing.T) {
+	c := newTestCamera(t, 8, 8)
+	atlas := ebiten.NewImage(64, 64)
+	frame := image.Rect(16, 24, 32, 40)
+	sprite := &testAtlasSprite{newTestSprite(6.5, 4, atlas), frame}
+	c.Update([]Sprite{sprite})
+
+	stripes := castSpriteStripes(c, sprite)
+	if len(stripes) == 0 {
+		t.Fatal("sprite not cast")
+	}
+
+	spriteLvl := castSpriteLevel(c, sprite)
+	for _, x := range stripes {
+		if texRect := spriteLvl.Cts[x]; !texRect.In(frame) {
+			t.Fatalf("column %v: texture rect %v outside of frame %v", x, texRect, frame)
+		}
+	}
+
+	// the frame is sampled from its top left to its bottom right, not the rest of the atlas
+	tolerance := 2*frame.Dx()/len(stripes) + 1
+	first, last := spriteLvl.Cts[stripes[0]], spriteLvl.Cts[stripes[len(stripes)-1]]
+	if first.Min.X-frame.Min.X > tolerance || frame.Max.X-last.Max.X > tolerance {
+		t.Errorf("texture columns %v to %v, want %v to %v", first.Min.X, last.Max.X, frame.Min.X, frame.Max.X)
+	}
+	if first.Min.Y-frame.Min.Y > tolerance || frame.Max.Y-first.Max.Y > tolerance {
+		t.Errorf("texture rows %v to %v, want %v to %v", first.Min.Y, first.Max.Y, frame.Min.Y, frame.Max.Y)
+	}
+}
+
+// testFlippedSprite is a test atlas sprite that can be drawn mirrored horizontally
+type testFlippedSprite struct {
+	*testAtlasSprite
+	flip bool
+}
+
+func (s *testFlippedSprite) FlipHorizontal() bool {
+	return s.flip
+}
+
+func TestCastFlippedSprite(t *testing.T) {
+	atlas := ebiten.NewImage(64, 64)
+	frame := image.Rect(16, 24, 32, 40)
+
+	c := newTestCamera(t, 8, 8)
+	sprite := &testFlippedSprite{&testAtlasSprite{newTestSprite(6.5, 4.2, atlas), frame}, false}
+	c.Update([]Sprite{sprite})
+	stripes := castSpriteStripes(c, sprite)
+	if len(stripes) == 0 {
+		t.Fatal("sprite not cast")
+	}
+	mirrored := make(map[int]image.Rectangle)
+	for _, x := range stripes {
+		// mirror each texture column within the frame
+		texRect := *castSpriteLevel(c, sprite).Cts[x]
+		texRect.Min.X = frame.Min.X + frame.Max.X - 1 - texRect.Min.X
+		texRect.Max.X = texRect.Min.X + 1
+		mirrored[x] = texRect
+	}
+
+	sprite.flip = true
+	c.Update([]Sprite{sprite})
+	flippedStripes := castSpriteStripes(c, sprite)
+	if len(flippedStripes) != len(stripes) {
+		t.Fatalf("flipped sprite cast to %v columns, want %v", len(flippedStripes), len(stripes))
+	}
+	for _, x := range flippedStripes {
+		if got, want := *castSpriteLevel(c, sprite).Cts[x], mirrored[x]; got != want {
+			t.Errorf("column %v: flipped texture rect %v, want mirrored %v", x, got, want)
+		}
+	}
+}
+
+func TestCastSpriteAgainstWallDepthBias(t *testing.T) {
+	c := newTestCamera(t, 8, 8)
+	c.SetPosition(&geom.Vector2{X: 4, Y: 4})
+	texture := ebiten.NewImage(testTexSize, testTexSize)
+
+	tests := []struct {
+		name        string
+		x, y        float64
+		bias        float64
+		wantStripes int
+	}{
+		// the wall on the first level starts at X 7.0
+		{"against wall without bias", 7, 4, 0, 0},
+		{"against wall with bias", 7, 4, 0.01, 16},
+		{"against wall to the side with bias", 7, 2.5, 0.01, 16},
+		{"in front of wall without bias", 6.5, 4, 0, 18},
+		{"in front of wall with bias", 6.5, 4, 0.01, 18},
+		{"behind wall with bias", 7.5, 4, 0.01, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sprite := newTestSprite(tt.x, tt.y, texture)
+			c.SetSpriteDepthBias(tt.bias)
+			c.Update([]Sprite{sprite})
+
+			if got := len(castSpriteStripes(c, sprite)); got != tt.wantStripes {
+				t.Errorf("sprite cast to %v columns, want %v", got, tt.wantStripes)
+			}
+			if visible := sprite.screenRect != nil; visible != (tt.wantStripes > 0) {
+				t.Errorf("sprite screen rect %v, want visible %v", sprite.screenRect, tt.wantStripes > 0)
+			}
+		})
+	}
+}
+
+func BenchmarkUpdateDistantSprites(b *testing.B) {
+	// sprites spread across the far half of the map, beyond the LOD distance
+	sprites := newBenchmarkSprites(1000, benchmarkMapSize/2)
+
+	for _, lod := range []float64{-1, 8} {
+		name := "LOD off"
+		if lod >= 0 {
+			name = fmt.Sprintf("LOD %v", lod)
+		}
+
+		b.Run(name, func(b *testing.B) {
+			c := newBenchmarkCamera(b)
+			c.SetSpriteLOD(lod)
+			c.Update(sprites)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				c.Update(sprites)
+			}
+		})
+	}
+}
+
+func BenchmarkCastSprites(b *testing.B) {
+	for _, count := range []int{10, 100, 1000, 5000} {
+		sprites := newBenchmarkSprites(count, 3)
+
+		for _, concurrent := range []bool{false, true} {
+			name := fmt.Sprintf("%v sprites/serial", count)
+			if concurrent {
+				name = fmt.Sprintf("%v sprites/worker pool", count)
+			}
+
+			b.Run(name, func(b *testing.B) {
+				c := newBenchmarkCamera(b)
+				c.SetConcurrency(concurrent)
+				c.Update(sprites)
+
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					c.Update(sprites)
+				}
+			})
+		}
+	}
+}
+
+// testLeveledSprite is a test sprite on an upper elevation level
+type testLeveledSprite struct {
+	*testSprite
+	levelNum int
+}
+
+func (s *testLeveledSprite) LevelNum() int {
+	return s.levelNum
+}
+
+func TestCastSpriteLevelLighting(t *testing.T) {
+	c := newTestCamera(t, 8, 8)
+	texture := ebiten.NewImage(testTexSize, testTexSize)
+	ground := newTestSprite(6, 3.5, texture)
+	upper := &testLeveledSprite{newTestSprite(6, 4.5, texture), 1}
+	sprites := []Sprite{ground, upper}
+
+	spriteLighting := func(sprite Sprite) color.RGBA {
+		stripes := castSpriteStripes(c, sprite)
+		if len(stripes) == 0 {
+			t.Fatalf("sprite at %v not cast", sprite.Pos())
+		}
+		return *castSpriteLevel(c, sprite).St[stripes[0]]
+	}
+
+	c.Update(sprites)
+	groundLit, upperLit := spriteLighting(ground), spriteLighting(upper)
+
+	// only the sprite on the upper level is lit by its level lighting
+	c.SetLevelLighting(1, 0, -200)
+	c.Update(sprites)
+	if got := spriteLighting(ground); got != groundLit {
+		t.Errorf("first level sprite lighting %v changed to %v by upper level lighting", groundLit, got)
+	}
+	if got := spriteLighting(upper); got.R >= upperLit.R {
+		t.Errorf("upper level sprite lighting %v not dimmed from %v by its level lighting", got, upperLit)
+	}
+}
+
+// testTaggedSprite is a test sprite value that is not comparable since it holds a slice
+type testTaggedSprite struct {
+	*testSprite
+	tags []string
+}
+
+func TestUpdateNotComparableSprites(t *testing.T) {
+	c := newTestCamera(t, 8, 8)
+	texture := ebiten.NewImage(testTexSize, testTexSize)
+	near := newTestSprite(5.5, 4, texture)
+	behind := newTestSprite(2, 4, texture)
+	sprites := []Sprite{
+		testTaggedSprite{newTestSprite(6, 4, texture), []string{"far"}},
+		near,
+		behind,
+	}
+
+	// sprites that are not comparable are cast without being hashed or compared
+	for frame := 0; frame < 3; frame++ {
+		c.Update(sprites)
+	}
+	if sprites[0].(testTaggedSprite).screenRect == nil {
+		t.Error("sprite that is not comparable was not cast")
+	}
+
+	// comparable sprites can still be looked up, sorted after the farther sprite
+	if got := c.SpriteDistance(near); !geom.NearlyEqual(got, 1.5, 1e-9) {
+		t.Errorf("near sprite distance = %v, want 1.5", got)
+	}
+	if !c.IsSpriteVisible(near) {
+		t.Error("near sprite not visible")
+	}
+	if got := c.getSpriteOrdIndex(near); got != 1 {
+		t.Errorf("near sprite order = %v, want 1", got)
+	}
+
+	// culled and unknown sprites are not found
+	if got := c.SpriteDistance(behind); got != -1 {
+		t.Errorf("culled sprite distance = %v, want -1", got)
+	}
+	if c.IsSpriteVisible(newTestSprite(5.5, 4, texture)) {
+		t.Error("sprite not provided in the last update is visible")
+	}
+
+	// sprites that are not comparable are not found, without panicking
+	if got := c.SpriteDistance(sprites[0]); got != -1 {
+		t.Errorf("sprite that is not comparable distance = %v, want -1", got)
+	}
+	if c.IsSpriteVisible(testTaggedSprite{near, nil}) {
+		t.Error("sprite that is not comparable is visible")
+	}
+}
+
+func TestRemoveManagedSpriteKeepsLastUpdate(t *testing.T) {
+	c := newTestCamera(t, 8, 8)
+	texture := ebiten.NewImage(testTexSize, testTexSize)
+	far, mid, near := newTestSprite(6.5, 4, texture), newTestSprite(6, 4, texture), newTestSprite(5.5, 4, texture)
+	c.AddSprite(far)
+	c.AddSprite(mid)
+	c.AddSprite(near)
+	c.UpdateManaged()
+
+	// sprites from the last update are still looked up after removing managed sprites
+	c.RemoveSprite(far)
+	for _, sprite := range []*testSprite{far, mid, near} {
+		if want := sprite.Pos().X - 4; !geom.NearlyEqual(c.SpriteDistance(sprite), want, 1e-9) {
+			t.Errorf("sprite at %v distance = %v after remove, want %v", sprite.Pos(), c.SpriteDistance(sprite), want)
+		}
+		if castSpriteLevel(c, sprite) == nil {
+			t.Errorf("sprite at %v from the last update changed by remove", sprite.Pos())
+		}
+	}
+
+	c.UpdateManaged()
+	if c.SpriteDistance(far) != -1 {
+		t.Error("removed sprite cast in the next update")
+	}
+	if !geom.NearlyEqual(c.SpriteDistance(near), 1.5, 1e-9) {
+		t.Errorf("near sprite distance = %v, want 1.5", c.SpriteDistance(near))
+	}
+}
+
+func TestSpriteScreenRectRenderScale(t *testing.T) {
+	for _, scale := range []float64{1, 0.5, 0.7, 0.33} {
+		c := newTestCamera(t, 8, 8)
+		c.SetRenderScale(scale)
+		sprite := newTestSprite(6, 4.3, ebiten.NewImage(testTexSize, testTexSize))
+		c.Update([]Sprite{sprite})
+
+		stripes := castSpriteStripes(c, sprite)
+		if len(stripes) == 0 || sprite.screenRect == nil {
+			t.Fatalf("scale %v: sprite not cast", scale)
+		}
+		firstStripe, lastStripe := stripes[0], stripes[len(stripes)-1]
+
+		// screen columns within the sprite screen rect are exactly those raycasted by the sprite stripes,
+		// so the wall depth at them is the depth the sprite was tested against
+		rect := *sprite.screenRect
+		if x := c.toViewX(rect.Min.X - 1); x >= firstStripe {
+			t.Errorf("scale %v: screen column %v left of %v is raycasted column %v of the sprite", scale, rect.Min.X-1, rect, x)
+		}
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			if viewX := c.toViewX(x); viewX < firstStripe || viewX > lastStripe {
+				t.Errorf("scale %v: screen column %v of %v is raycasted column %v, outside of stripes %v to %v",
+					scale, x, rect, viewX, firstStripe, lastStripe)
+			}
+			if depth := c.DepthAt(x); depth != c.zBuffer[c.toViewX(x)] {
+				t.Errorf("scale %v: depth at screen column %v = %v, want %v", scale, x, depth, c.zBuffer[c.toViewX(x)])
+			}
+		}
+		if x := c.toViewX(rect.Max.X); x <= lastStripe {
+			t.Errorf("scale %v: screen column %v right of %v is raycasted column %v of the sprite", scale, rect.Max.X, rect, x)
+		}
+
+		// rows are converted the same way as wall draw rows
+		spriteLvl := castSpriteLevel(c, sprite)
+		if want := c.toScreenY(spriteLvl.Sv[firstStripe].Min.Y); rect.Min.Y != want {
+			t.Errorf("scale %v: screen rect top %v, want %v", scale, rect.Min.Y, want)
+		}
+		info := c.ColumnInfo(rect.Min.X)
+		if want := c.toScreenY(c.columns[c.toViewX(rect.Min.X)].DrawStart); info.DrawStart != want {
+			t.Errorf("scale %v: column draw start %v, want %v", scale, info.DrawStart, want)
+		}
+	}
+}
+
+func TestScaleCeil(t *testing.T) {
+	tests := []struct {
+		v, num, den, want int
+	}{
+		{3, 64, 64, 3},
+		{3, 64, 32, 6},
+		{3, 64, 44, 5},
+		{0, 64, 44, 0},
+		{-3, 64, 44, -4},
+		{44, 64, 44, 64},
+	}
+
+	for _, tt := range tests {
+		if got := scaleCeil(tt.v, tt.num, tt.den); got != tt.want {
+			t.Errorf("scaleCeil(%v, %v, %v) = %v, want %v", tt.v, tt.num, tt.den, got, tt.want)
+		}
+	}
+}
@@ -13,3 +13,10 @@ type TextureHandler interface {
 	// FloorTextureAt returns image used for textured floor at the given x, y map coordinates
 	FloorTextureAt(x, y int) *image.RGBA
 }
+
+// CeilingTextureHandler is an optional interface a TextureHandler can implement to provide
+// ceiling textures for each map coordinate, instead of the single texture from camera.SetCeilingTexture
+type CeilingTextureHandler interface {
+	// CeilingTextureAt returns image used for textured ceiling at the given x, y map coordinates (nil to show sky)
+	CeilingTextureAt(x, y int) *image.RGBA
+}